@@ -24,7 +24,7 @@ func init() {
 		switch r.Method {
 
 		case http.MethodPut:
-			// FIXME: THIS ONLY WORKS FOR RDS AND MVPS!
+			// FIXME: THIS ONLY WORKS FOR RDS AND MVPS! Use POST /admin/reconcile-labels/<pwd> for all modules
 			adminMode, err := variables.Get("admin_mode")
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -56,7 +56,7 @@ func init() {
 				return
 			}
 
-			fmt.Println("Setting label for " + kvEntry.Key + " and " + kvEntry.Value)
+			shared.LogInfo("Setting label", shared.Field("key", kvEntry.Key), shared.Field("target", kvEntry.Value))
 
 			store, err := kv.OpenStore("default")
 			if err != nil {
@@ -77,7 +77,12 @@ func init() {
 			}
 			
 			moduleKey := strings.Split(kvEntry.Key, "-")[0]
-			err = shared.SetLabel("ms-" + moduleKey, string(kvEntry.Value), accessJwt, endpoint)
+			entry := shared.ParseStoreEntry(valueFromStore)
+			target := entry.Did
+			if target == "" {
+				target = entry.Handle
+			}
+			err = shared.SetLabel("ms-"+moduleKey, target, accessJwt, endpoint)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -88,6 +93,178 @@ func init() {
 
 			fmt.Fprintln(w, "Label ms-" + moduleKey + " set for " + kvEntry.Value)
 
+		case http.MethodPost:
+			adminMode, err := variables.Get("admin_mode")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			if adminMode != "true" {
+				http.Error(w, "admin mode not enabled", http.StatusUnauthorized)
+				return
+			}
+
+			accessJwt, endpoint, err := shared.LoginToBskyWithReq(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			// operation is the segment before the password, e.g. /admin/migrate-dids/<pwd>
+			segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+			operation := ""
+			if len(segments) >= 3 {
+				operation = segments[len(segments)-2]
+			}
+
+			switch operation {
+			case "migrate-dids":
+				shared.LogInfo("Migrating k/v entries from handles to DIDs")
+				migrated, failed, err := shared.MigrateStoreToDids(accessJwt, endpoint)
+				if err != nil {
+					http.Error(w, "Error migrating k/v entries: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintf(w, "Migrated %d entries\n", migrated)
+				for _, key := range failed {
+					fmt.Fprintln(w, "Could not migrate "+key)
+				}
+
+			case "dedupe-lists":
+				shared.LogInfo("Removing duplicate listitems from all lists and starter packs")
+				removed, err := shared.DedupeListItems(accessJwt, endpoint)
+				if err != nil {
+					http.Error(w, "Error removing duplicate listitems: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintf(w, "Removed %d duplicate listitems\n", removed)
+
+			case "rebuild-list-index":
+				shared.LogInfo("Rebuilding the list index")
+				indexed, err := shared.RebuildListIndex(accessJwt, endpoint)
+				if err != nil {
+					http.Error(w, "Error rebuilding the list index: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintf(w, "Indexed %d lists\n", indexed)
+
+			case "publish-labels":
+				shared.LogInfo("Publishing the label definitions of all modules")
+				definitions, err := shared.PublishLabelDefinitions(accessJwt, endpoint)
+				if err != nil {
+					http.Error(w, "Error publishing label definitions: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				jsonResult, err := json.Marshal(definitions)
+				if err != nil {
+					http.Error(w, "Error encoding label definitions to JSON: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintln(w, string(jsonResult))
+
+			case "reconcile-labels":
+				// only reports the differences unless ?apply=true is set
+				apply := r.URL.Query().Get("apply") == "true"
+				shared.LogInfo("Reconciling labels with the k/v store", shared.Field("apply", apply))
+				defer shared.ReserveForHeavyOperation(endpoint)()
+				result, err := shared.ReconcileLabels(apply, accessJwt, endpoint)
+				if err != nil {
+					http.Error(w, "Error reconciling labels: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				jsonResult, err := json.Marshal(result)
+				if err != nil {
+					http.Error(w, "Error encoding result to JSON: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintln(w, string(jsonResult))
+
+			case "approvals":
+				shared.LogInfo("Listing challenge verifications waiting for approval")
+				approvals, err := shared.GetApprovals()
+				if err != nil {
+					http.Error(w, "Error reading verifications waiting for approval: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				jsonResult, err := json.Marshal(approvals)
+				if err != nil {
+					http.Error(w, "Error encoding result to JSON: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintln(w, string(jsonResult))
+
+			case "approve":
+				// the key of the verification as listed by approvals, e.g. ?key=approval-javachamps-<did>-<id>
+				key := r.URL.Query().Get("key")
+				shared.LogInfo("Approving challenge verification", shared.Field("key", key))
+				result, err := shared.ApproveVerification(key, accessJwt, endpoint)
+				if err != nil {
+					http.Error(w, "Error approving "+key+": "+err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				jsonResult, err := json.Marshal(result)
+				if err != nil {
+					http.Error(w, "Error encoding result to JSON: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintln(w, string(jsonResult))
+
+			case "reject":
+				key := r.URL.Query().Get("key")
+				shared.LogInfo("Rejecting challenge verification", shared.Field("key", key))
+				err := shared.RejectVerification(key)
+				if err != nil {
+					http.Error(w, "Error rejecting "+key+": "+err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintln(w, "Rejected "+key)
+
+			case "retry-pending":
+				shared.LogInfo("Retrying pending verifications")
+				result, err := shared.RetryPendingVerifications(accessJwt, endpoint)
+				if err != nil {
+					http.Error(w, "Error retrying pending verifications: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				jsonResult, err := json.Marshal(result)
+				if err != nil {
+					http.Error(w, "Error encoding result to JSON: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintln(w, string(jsonResult))
+
+			default:
+				http.Error(w, "unknown operation", http.StatusBadRequest)
+			}
+
 		case http.MethodDelete:
 			adminMode, err := variables.Get("admin_mode")
 			if err != nil {
@@ -100,11 +277,12 @@ func init() {
 				return
 			}
 
-			fmt.Println("Deleting all Starter Packs and Lists")
+			shared.LogInfo("Deleting all Starter Packs and Lists")
 			accessJwt, endpoint, err := shared.LoginToBskyWithReq(r)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusUnauthorized)
 			}
+			defer shared.ReserveForHeavyOperation(endpoint)()
 
 			starterPacks, err := shared.GetStarterPacks(accessJwt, endpoint)
 			if err != nil {
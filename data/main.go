@@ -13,8 +13,9 @@ import (
 )
 
 type KVEntry struct {
-	Key   string
-	Value string
+	Key    string
+	Value  string
+	Record *shared.StoreEntry `json:",omitempty"`
 }
 
 func init() {
@@ -23,7 +24,7 @@ func init() {
 		switch r.Method {
 
 		case http.MethodGet:
-			fmt.Println("Getting KV entries")
+			shared.LogInfo("Getting KV entries")
 			_, _, err := shared.LoginToBskyWithReq(r)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -45,7 +46,7 @@ func init() {
 
 			kvEntries := make([]KVEntry, 0)
 			for _, key := range keys {
-				if (key == "endpoint") || (key == "accessJwt") || (key == "") {
+				if !shared.IsVerificationKey(key) {
 					continue
 				}
 				value, err := store.Get(key)
@@ -53,7 +54,9 @@ func init() {
 					http.Error(w, err.Error(), http.StatusInternalServerError)
 					return
 				}
-				kvEntries = append(kvEntries, KVEntry{key, string(value)})
+				// the raw value is kept so the export can be imported again, the record is the decoded form of it
+				record := shared.ParseStoreEntryWithKey(key, value)
+				kvEntries = append(kvEntries, KVEntry{key, string(value), &record})
 			}
 
 			jsonResult, err := json.Marshal(kvEntries)
@@ -95,7 +98,7 @@ func init() {
 
 			resp, err := shared.SendGet(backendUrl, "")
 			if err != nil {
-				http.Error(w, "Error getting data from backend URL "+backendUrl+": "+err.Error(), http.StatusInternalServerError)
+				http.Error(w, "Error getting data from backend URL "+shared.RedactUrl(backendUrl)+": "+err.Error(), http.StatusInternalServerError)
 				return
 			}
 
@@ -114,7 +117,7 @@ func init() {
 			defer store.Close()
 
 			for _, entry := range kvEntries {
-				fmt.Println("Setting KV entry: <" + entry.Key + "> = <" + entry.Value + ">")
+				shared.LogDebug("Setting KV entry", shared.Field("key", entry.Key))
 				err = store.Set(entry.Key, []byte(entry.Value))
 				if err != nil {
 					http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -122,6 +125,7 @@ func init() {
 				}
 			}
 
+			shared.LogInfo("Imported KV entries", shared.Field("count", len(kvEntries)))
 			w.WriteHeader(http.StatusOK)
 
 		default:
@@ -0,0 +1,5 @@
+module github.com/labeler-stream
+
+go 1.20
+
+require golang.org/x/net v0.33.0
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// labelFramesResponse is what the labeler component returns at /labeler/frames
+type labelFramesResponse struct {
+	Cursor int64    `json:"cursor"`
+	Frames [][]byte `json:"frames"`
+	Closed bool     `json:"closed,omitempty"`
+}
+
+var (
+	labelerUrl   = envOrDefault("LABELER_URL", "http://localhost:3000")
+	listenAddr   = envOrDefault("LISTEN_ADDR", ":8080")
+	pollInterval = 5 * time.Second
+	client       = &http.Client{Timeout: 30 * time.Second}
+)
+
+func envOrDefault(name string, defaultValue string) string {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return defaultValue
+	}
+	return strings.TrimRight(value, "/")
+}
+
+// getFrames polls the labeler component for the frames after the cursor, signed with the key only it has
+func getFrames(cursor string) (labelFramesResponse, error) {
+	requestUrl := labelerUrl + "/labeler/frames"
+	if cursor != "" {
+		requestUrl += "?cursor=" + url.QueryEscape(cursor)
+	}
+	resp, err := client.Get(requestUrl)
+	if err != nil {
+		return labelFramesResponse{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return labelFramesResponse{}, fmt.Errorf("Labeler returned status code %d", resp.StatusCode)
+	}
+
+	var response labelFramesResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	return response, err
+}
+
+// subscribeLabels streams the label frames as binary WebSocket messages like com.atproto.label.subscribeLabels
+func subscribeLabels(ws *websocket.Conn) {
+	defer ws.Close()
+	cursor := ws.Request().URL.Query().Get("cursor")
+	log.Printf("Subscription from %s with cursor %q", ws.Request().RemoteAddr, cursor)
+
+	// subscribers don't send messages, reading only notices when they disconnect
+	closed := make(chan struct{})
+	go func() {
+		var message []byte
+		for websocket.Message.Receive(ws, &message) == nil {
+		}
+		close(closed)
+	}()
+	wait := func() bool {
+		select {
+		case <-closed:
+			log.Printf("Subscription from %s closed", ws.Request().RemoteAddr)
+			return false
+		case <-time.After(pollInterval):
+			return true
+		}
+	}
+
+	for {
+		response, err := getFrames(cursor)
+		if err != nil {
+			log.Printf("Error getting label frames: %v", err)
+			if !wait() {
+				return
+			}
+			continue
+		}
+		for _, frame := range response.Frames {
+			err = websocket.Message.Send(ws, frame)
+			if err != nil {
+				log.Printf("Subscription from %s closed: %v", ws.Request().RemoteAddr, err)
+				return
+			}
+		}
+		if response.Closed {
+			return
+		}
+		cursor = strconv.FormatInt(response.Cursor, 10)
+		if len(response.Frames) == 0 && !wait() {
+			return
+		}
+	}
+}
+
+// The labeler component runs in Spin, which can't upgrade to a WebSocket. This service serves the
+// com.atproto.label.subscribeLabels stream next to it, the reverse proxy routes that method here
+func main() {
+	if value := os.Getenv("POLL_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid POLL_INTERVAL %q: %v", value, err)
+		}
+		pollInterval = interval
+	}
+
+	// relays and AppViews don't send an Origin header, so it isn't checked
+	http.Handle("/xrpc/com.atproto.label.subscribeLabels", websocket.Server{Handler: subscribeLabels})
+	log.Printf("Streaming labels of %s on %s", labelerUrl, listenAddr)
+	log.Fatal(http.ListenAndServe(listenAddr, nil))
+}
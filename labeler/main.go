@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
+	"github.com/shared"
+)
+
+type QueryLabelsResponse struct {
+	Cursor string               `json:"cursor,omitempty"`
+	Labels []shared.SignedLabel `json:"labels"`
+}
+
+func xrpcError(w http.ResponseWriter, errorName string, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"error": errorName, "message": message})
+}
+
+// Serves com.atproto.label.queryLabels, the frames of com.atproto.label.subscribeLabels for labeler-stream at
+// /labeler/frames and the public key of the signing key at /labeler/did-key. This is the only component with the key
+func init() {
+	spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/labeler/did-key":
+			didKey, err := shared.LabelerDidKey()
+			if err != nil {
+				http.Error(w, "Error reading the labeler signing key: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, didKey)
+			return
+
+		case "/labeler/frames":
+			// polled by labeler-stream, which keeps the WebSocket connections that Spin can't upgrade to
+			response, err := shared.LabelFrames(r.URL.Query().Get("cursor"), 250)
+			if err != nil {
+				http.Error(w, "Error reading label frames: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/labeler/") {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+
+		switch r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:] {
+		case "com.atproto.label.queryLabels":
+			uriPatterns := query["uriPatterns"]
+			if len(uriPatterns) == 0 {
+				xrpcError(w, "InvalidRequest", "uriPatterns is required", http.StatusBadRequest)
+				return
+			}
+			limit := 50
+			if query.Get("limit") != "" {
+				parsed, err := strconv.Atoi(query.Get("limit"))
+				if err != nil || parsed < 1 || parsed > 250 {
+					xrpcError(w, "InvalidRequest", "limit must be between 1 and 250", http.StatusBadRequest)
+					return
+				}
+				limit = parsed
+			}
+
+			labels, nextCursor, err := shared.QueryLabels(uriPatterns, query["sources"], limit, query.Get("cursor"))
+			if err != nil {
+				xrpcError(w, "InternalServerError", "Error querying labels: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			response := QueryLabelsResponse{Cursor: nextCursor, Labels: labels}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(response)
+
+		default:
+			xrpcError(w, "MethodNotImplemented", "Method not implemented", http.StatusNotImplemented)
+		}
+	})
+}
+
+func main() {}
@@ -1,17 +1,17 @@
-module github.com/validate_cncfamb
+module github.com/modules
 
 go 1.20
 
 require (
-	github.com/antchfx/htmlquery v1.3.4
 	github.com/fermyon/spin/sdk/go/v2 v2.2.0
+	github.com/shared v0.0.0-00010101000000-000000000000
 )
 
 require (
+	github.com/antchfx/htmlquery v1.3.4 // indirect
 	github.com/antchfx/xpath v1.3.3 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/julienschmidt/httprouter v1.3.0 // indirect
-	github.com/shared v0.0.0-00010101000000-000000000000
 	golang.org/x/net v0.33.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 )
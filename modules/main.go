@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+
+	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
+	"github.com/shared"
+)
+
+func init() {
+	spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			shared.LogInfo("Getting all registered modules")
+			err := shared.RespondWithAllModules(w)
+			if err != nil {
+				http.Error(w, "Error encoding modules to JSON: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func main() {}
@@ -3,17 +3,20 @@
 baseurl="http://localhost:3000"
 # baseurl="https://verifiedbsky.net"
 
-#accounts=$(curl -s "$baseurl/admin/data/${SPIN_VARIABLE_BSKY_PASSWORD}" | jq -r '.[].Value')
-accounts=$(echo '[{"Key":"dynamicsminds-Tobias Fenster","Value":"tobiasfenster.io"},{"Key":"rd-2efc9bb2-6a8c-e711-811e-3863bb36edf8","Value":"tobiasfenster.io"},{"Key":"colorcloud-Tobias Fenster","Value":"tobiasfenster.io"},{"Key":"mvp-2efc9bb2-6a8c-e711-811e-3863bb36edf8","Value":"tobiasfenster.io"}]' | jq -r '.[].Value')
+#accounts=$(curl -s "$baseurl/admin/data/${SPIN_VARIABLE_BSKY_PASSWORD}" | jq -r '.[] | (.Value | fromjson? | .did | select(. != "")) // .Value')
+accounts=$(echo '[{"Key":"dynamicsminds-Tobias Fenster","Value":"tobiasfenster.io"},{"Key":"rd-2efc9bb2-6a8c-e711-811e-3863bb36edf8","Value":"{\"did\":\"did:plc:example\",\"handle\":\"tobiasfenster.io\"}"},{"Key":"colorcloud-Tobias Fenster","Value":"tobiasfenster.io"},{"Key":"mvp-2efc9bb2-6a8c-e711-811e-3863bb36edf8","Value":"tobiasfenster.io"}]' | jq -r '.[] | (.Value | fromjson? | .did | select(. != "")) // .Value')
 accounts=$(echo "$accounts" | tr ' ' '\n' | sort -u | tr '\n' ' ')
 # echo $accounts
 
-for handle in $accounts; do
-    echo "Validating account: $handle"
+for account in $accounts; do
+    echo "Validating account: $account"
             
     # Get current validation status for all modules (with authentication)
-    validation_response=$(curl -s "$baseurl/weekly-validation/$handle/${SPIN_VARIABLE_BSKY_PASSWORD}")
+    validation_response=$(curl -s "$baseurl/weekly-validation/$account/${SPIN_VARIABLE_BSKY_PASSWORD}")
     echo "Validation response: $validation_response"
+    did=$(echo "$validation_response" | jq -r '.did // ""')
+    handle=$(echo "$validation_response" | jq -r '.bskyHandle // ""')
+    handle=${handle:-$account}
     
     # Parse the response and process each module
     echo "$validation_response" | jq -r '.moduleResults | to_entries[] | @base64' | while IFS= read -r module_data; do
@@ -29,7 +32,7 @@ for handle in $accounts; do
         echo "Account $handle module $module_key is valid, resetting failure count to 0"
         curl -s -X POST "$baseurl/weekly-validation/${SPIN_VARIABLE_BSKY_PASSWORD}" \
             -H "Content-Type: application/json" \
-            -d "{\"bskyHandle\": \"$handle\", \"moduleKey\": \"$module_key\", \"failureCount\": 0}"
+            -d "{\"did\": \"$did\", \"bskyHandle\": \"$handle\", \"moduleKey\": \"$module_key\", \"failureCount\": 0}"
         else
         # Module validation is invalid, increment failure count
         new_failure_count=$((current_failure_count + 1))
@@ -37,7 +40,7 @@ for handle in $accounts; do
 
         response=$(curl -s -X POST "$baseurl/weekly-validation/${SPIN_VARIABLE_BSKY_PASSWORD}" \
             -H "Content-Type: application/json" \
-            -d "{\"bskyHandle\": \"$handle\", \"moduleKey\": \"$module_key\", \"failureCount\": $new_failure_count}")
+            -d "{\"did\": \"$did\", \"bskyHandle\": \"$handle\", \"moduleKey\": \"$module_key\", \"failureCount\": $new_failure_count}")
         
         echo "Update response: $response"
         
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"strings"
@@ -11,16 +12,15 @@ import (
 
 	"github.com/fermyon/spin/sdk/go/v2/kv"
 	"github.com/fermyon/spin/sdk/go/v2/variables"
-
-	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
 )
 
 type AuthResponse struct {
-	Did       string `json:"did"`
-	DidDoc    DidDoc `json:"didDoc"`
-	Handle    string `json:"handle"`
-	Email     string `json:"email"`
-	AccessJwt string `json:"accessJwt"`
+	Did        string `json:"did"`
+	DidDoc     DidDoc `json:"didDoc"`
+	Handle     string `json:"handle"`
+	Email      string `json:"email"`
+	AccessJwt  string `json:"accessJwt"`
+	RefreshJwt string `json:"refreshJwt"`
 }
 
 type StarterPackResponse struct {
@@ -102,6 +102,20 @@ type ProfileResponse struct {
 	DID         string `json:"did"`
 	Handle      string `json:"handle"`
 	DisplayName string `json:"displayName"`
+	Description string `json:"description"`
+}
+
+type AuthorFeedResponse struct {
+	Feed []struct {
+		Post struct {
+			Author struct {
+				DID string `json:"did"`
+			} `json:"author"`
+			Record struct {
+				Text string `json:"text"`
+			} `json:"record"`
+		} `json:"post"`
+	} `json:"feed"`
 }
 
 type ListOrStarterPackWithUrl struct {
@@ -125,86 +139,77 @@ type Label struct {
 	Src string `json:"src"`
 	Uri string `json:"uri"`
 	Val string `json:"val"`
+	Exp string `json:"exp,omitempty"`
 }
 
 func AddToBskyStarterPacksAndList(naming Naming, moduleKey string, bskyHandle string, bskyDid string, label string, accessJwt string, endpoint string) ([]ListOrStarterPackWithUrl, error) {
-	starterPacks, err := GetStarterPacks(accessJwt, endpoint)
+	bskyHandleOwner, err := variables.Get("bsky_handle")
 	if err != nil {
 		return []ListOrStarterPackWithUrl{}, err
 	}
 
-	lists, err := GetLists(accessJwt, endpoint)
+	result, err := AddToBskyStarterPacksAndListWithResult(naming, bskyDid, accessJwt, endpoint)
 	if err != nil {
 		return []ListOrStarterPackWithUrl{}, err
 	}
-
-	addedToElements := make([]ListOrStarterPackWithUrl, 0)
-	bskyHandleOwner, err := variables.Get("bsky_handle")
+	err = result.Err()
 	if err != nil {
-		return []ListOrStarterPackWithUrl{}, err
+		LogError("Error adding user to starter packs and lists", Field("did", bskyDid), Field("error", err))
+		return result.Succeeded(bskyHandleOwner), err
 	}
 
-	starterPack, err := AddUserToStarterPack(bskyHandle, bskyDid, naming.Title, naming.Description, starterPacks, accessJwt, endpoint)
+	_, err = Follow(bskyDid, accessJwt, endpoint)
 	if err != nil {
-		return []ListOrStarterPackWithUrl{}, err
+		// only print the error as this is not technically blocking the application usecase
+		LogWarn("Error following user", Field("did", bskyDid), Field("error", err))
 	}
-	addedToElements = append(addedToElements, ConvertToStruct(starterPack, naming.Title, "sp", bskyHandleOwner))
 
-	list, err := AddUserToList(bskyDid, naming.Title, lists, accessJwt, endpoint)
+	err = SetLabel(label, bskyDid, accessJwt, endpoint)
 	if err != nil {
-		return []ListOrStarterPackWithUrl{}, err
+		LogError("Error setting label on user", Field("label", label), Field("did", bskyDid), Field("error", err))
+		return []ListOrStarterPackWithUrl{}, fmt.Errorf("Error setting label " + label + " on user: " + err.Error())
 	}
-	addedToElements = append(addedToElements, ConvertToStruct(list, naming.Title, "list", bskyHandleOwner))
 
-	for first, secondArray := range naming.FirstAndSecondLevel {
-		starterPack, err = AddUserToStarterPack(bskyHandle, bskyDid, first.Title, first.Description, starterPacks, accessJwt, endpoint)
-		if err != nil {
-			return []ListOrStarterPackWithUrl{}, err
-		}
-		addedToElements = append(addedToElements, ConvertToStruct(starterPack, first.Title, "sp", bskyHandleOwner))
-
-		list, err = AddUserToList(bskyDid, first.Title, lists, accessJwt, endpoint)
-		if err != nil {
-			return []ListOrStarterPackWithUrl{}, err
-		}
-		addedToElements = append(addedToElements, ConvertToStruct(list, first.Title, "list", bskyHandleOwner))
-
-		for _, second := range secondArray {
-			starterPack, err = AddUserToStarterPack(bskyHandle, bskyDid, second.Title, second.Description, starterPacks, accessJwt, endpoint)
-			if err != nil {
-				return []ListOrStarterPackWithUrl{}, err
-			}
-			addedToElements = append(addedToElements, ConvertToStruct(starterPack, second.Title, "sp", bskyHandleOwner))
+	return result.Succeeded(bskyHandleOwner), nil
+}
 
-			list, err = AddUserToList(bskyDid, second.Title, lists, accessJwt, endpoint)
-			if err != nil {
-				return []ListOrStarterPackWithUrl{}, err
-			}
-			addedToElements = append(addedToElements, ConvertToStruct(list, second.Title, "list", bskyHandleOwner))
-		}
+// AddToBskyStarterPacksAndListWithResult adds the user to the starter packs and lists of all levels with batched writes
+// and reports for each of them if it succeeded
+func AddToBskyStarterPacksAndListWithResult(naming Naming, bskyDid string, accessJwt string, endpoint string) (MembershipResult, error) {
+	starterPacks, err := GetStarterPacks(accessJwt, endpoint)
+	if err != nil {
+		return MembershipResult{}, err
 	}
 
-	_, err = Follow(bskyDid, accessJwt, endpoint)
+	lists, err := GetLists(accessJwt, endpoint)
 	if err != nil {
-		// only print the error as this is not technically blocking the application usecase
-		fmt.Println("Error following user: " + err.Error())
+		return MembershipResult{}, err
 	}
 
-	err = SetLabel(label, bskyHandle, accessJwt, endpoint)
-	if err != nil {
-		fmt.Println("Error setting label " + label + " on user: " + err.Error())
-		return []ListOrStarterPackWithUrl{}, fmt.Errorf("Error setting label " + label + " on user: " + err.Error())
+	timestamp := time.Now().Format("2006-01-02T15:04:05.000Z")
+	changes := []MembershipChange{}
+	plan := func(title string, description string) {
+		changes = append(changes, PlanStarterPackMembership(bskyDid, title, description, starterPacks, timestamp, accessJwt, endpoint))
+		changes = append(changes, PlanListMembership(bskyDid, title, lists, timestamp, accessJwt, endpoint))
+	}
+
+	plan(naming.Title, naming.Description)
+	for first, secondArray := range naming.FirstAndSecondLevel {
+		plan(first.Title, first.Description)
+		for _, second := range secondArray {
+			plan(second.Title, second.Description)
+		}
 	}
 
-	return addedToElements, nil
+	return ApplyMembershipChanges(changes, accessJwt, endpoint), nil
 }
 
 func ConvertToStruct(uri string, title string, listOrStarterPack string, bskyHandle string) ListOrStarterPackWithUrl {
 	ref := uri[strings.LastIndex(uri, "/")+1:]
 	if listOrStarterPack == "sp" {
-		return ListOrStarterPackWithUrl{URL: "https://bsky.app/starter-pack/" + bskyHandle + "/" + ref, Title: "Starter pack " + title}
+		return ListOrStarterPackWithUrl{URL: WebUrl() + "/starter-pack/" + bskyHandle + "/" + ref, Title: "Starter pack " + title}
 	} else {
-		return ListOrStarterPackWithUrl{URL: "https://bsky.app/profile/" + bskyHandle + "/lists/" + ref, Title: "List " + title}
+		return ListOrStarterPackWithUrl{URL: WebUrl() + "/profile/" + bskyHandle + "/lists/" + ref, Title: "List " + title}
 	}
 }
 
@@ -215,25 +220,42 @@ func LoginToBsky() (string, string, error) {
 	}
 	defer store.Close()
 
-	accessJwtFromStore, err := store.Get("accessJwt")
-	if err != nil && err.Error() != "no such key" {
+	accessJwtFromStore, err := getFromStore(store, accessJwtKey)
+	if err != nil {
 		return "", "", err
 	}
-	if accessJwtFromStore != nil && string(accessJwtFromStore) != "" {
-		// fmt.Println("Check if accessJwt is still valid")
-		url := "https://bsky.social/xrpc/com.atproto.server.getSession"
-		sessionResponse, err := SendGetLogConfigurable(url, string(accessJwtFromStore), true)
-		if err == nil && sessionResponse.StatusCode == 200 {
-			// fmt.Println("AccessJwt is still valid")
-			endpointFromStore, err := store.Get("endpoint")
-			if err != nil {
-				return "", "", err
+	endpointFromStore, err := getFromStore(store, endpointKey)
+	if err != nil {
+		return "", "", err
+	}
+	if accessJwtFromStore != "" && endpointFromStore != "" {
+		// the expiry is checked locally, only tokens without a readable expiry need a request
+		current, ok := jwtIsCurrent(accessJwtFromStore, time.Now())
+		if current {
+			return accessJwtFromStore, endpointFromStore, nil
+		}
+		if !ok {
+			client := NewXrpcClient(endpointFromStore, accessJwtFromStore)
+			client.NoLog = true
+			if client.Query("com.atproto.server.getSession", nil, nil) == nil {
+				return accessJwtFromStore, endpointFromStore, nil
 			}
-			return string(accessJwtFromStore), string(endpointFromStore), nil
 		}
 	}
 
-	fmt.Println("No accessJwt in store or not valid anymore, logging in again")
+	refreshJwtFromStore, err := getFromStore(store, refreshJwtKey)
+	if err != nil {
+		return "", "", err
+	}
+	if refreshJwtFromStore != "" && endpointFromStore != "" {
+		accessJwt, err := RefreshSession(store, refreshJwtFromStore, endpointFromStore)
+		if err == nil {
+			return accessJwt, endpointFromStore, nil
+		}
+		LogWarn("Refreshing the session failed, logging in again", Field("error", err))
+	}
+
+	LogInfo("No valid session in store, logging in again")
 	bskyPwd, err := variables.Get("bsky_password")
 	if err != nil {
 		return "", "", err
@@ -254,59 +276,95 @@ func LoginToBskyWithReq(r *http.Request) (string, string, error) {
 }
 
 func LoginToBskyWithPwd(bskyPwd string) (string, string, error) {
-	fmt.Println("Trying to log in to Bluesky")
-	url := "https://bsky.social/xrpc/com.atproto.server.createSession"
+	LogInfo("Trying to log in to Bluesky")
 	bskyHandle, err := variables.Get("bsky_handle")
 	if err != nil {
 		return "", "", err
 	}
+	bskyDid, err := variables.Get("bsky_did")
+	if err != nil {
+		return "", "", err
+	}
 
-	payload := "{\"identifier\": \"" + bskyHandle + "\",\"password\": \"" + bskyPwd + "\"}"
+	// the session has to be created on the PDS that hosts the account
+	pdsEndpoint := PdsUrl()
+	if pdsEndpoint == "" {
+		pdsEndpoint, err = ResolvePdsEndpoint(bskyDid)
+		if err != nil {
+			return "", "", err
+		}
+	}
 
-	resp, err := SendPost(url, payload, "")
-	defer resp.Body.Close()
+	// the credentials must not end up in the log
+	client := NewXrpcClient(pdsEndpoint, "")
+	client.NoLog = true
 
 	var response AuthResponse
-	err = json.NewDecoder(resp.Body).Decode(&response)
+	err = client.Procedure("com.atproto.server.createSession", CreateSessionRequest{Identifier: bskyHandle, Password: bskyPwd}, &response)
 	if err != nil {
 		return "", "", err
 	}
 
-	fmt.Println("Logged in successfully")
+	LogInfo("Logged in successfully")
 	store, err := kv.OpenStore("default")
 	if err != nil {
 		return "", "", err
 	}
 	defer store.Close()
 
-	fmt.Println("Storing accessJwt and endpoint")
-	err = store.Set("accessJwt", []byte(response.AccessJwt))
-	if err != nil {
-		fmt.Println("Error storing accessJwt: " + err.Error())
+	// a configured PDS wins over the one in the DID document, e.g. for a local test PDS
+	endpoint, err := response.DidDoc.PdsEndpoint()
+	if err != nil || PdsUrl() != "" {
+		endpoint = pdsEndpoint
 	}
-	err = store.Set("endpoint", []byte(response.DidDoc.Service[0].ServiceEndpoint))
-	if err != nil {
-		fmt.Println("Error storing endpoint: " + err.Error())
-	}
-	return response.AccessJwt, response.DidDoc.Service[0].ServiceEndpoint, nil
+	storeSession(store, response, endpoint)
+	return response.AccessJwt, endpoint, nil
 }
 
 func GetProfile(bskyHandle string, accessJwt string, endpoint string) (ProfileResponse, error) {
-	fmt.Println("Getting profile for Bluesky handle " + bskyHandle)
-	url := endpoint + "/xrpc/app.bsky.actor.getProfile?actor=" + url.QueryEscape(bskyHandle)
+	LogDebug("Getting profile", Field("actor", bskyHandle))
 
-	resp, err := SendGet(url, accessJwt)
+	var response ProfileResponse
+	err := NewAppViewClient(endpoint, accessJwt).Query("app.bsky.actor.getProfile", url.Values{"actor": {bskyHandle}}, &response)
 	if err != nil {
 		return ProfileResponse{}, err
 	}
+	LogDebug("Got profile successfully", Field("did", response.DID))
+	return response, nil
+}
 
-	var response ProfileResponse
-	err = json.NewDecoder(resp.Body).Decode(&response)
+// ResolveDid returns the DID for a handle. If a DID is passed, it is returned as is.
+func ResolveDid(handleOrDid string, accessJwt string, endpoint string) (string, error) {
+	if strings.HasPrefix(handleOrDid, "did:") {
+		return handleOrDid, nil
+	}
+	profile, err := GetProfile(handleOrDid, accessJwt, endpoint)
 	if err != nil {
-		return ProfileResponse{}, err
+		return "", err
 	}
-	fmt.Println("Got profile successfully with DID " + response.DID)
-	return response, nil
+	if profile.DID == "" {
+		return "", fmt.Errorf("Could not resolve DID for " + handleOrDid)
+	}
+	return profile.DID, nil
+}
+
+// GetRecentPostTexts returns the texts of the latest posts of an account, without replies and reposts
+func GetRecentPostTexts(bskyDid string, accessJwt string, endpoint string) ([]string, error) {
+	params := url.Values{"actor": {bskyDid}, "filter": {"posts_no_replies"}, "limit": {"30"}}
+
+	var response AuthorFeedResponse
+	err := NewAppViewClient(endpoint, accessJwt).Query("app.bsky.feed.getAuthorFeed", params, &response)
+	if err != nil {
+		return []string{}, err
+	}
+
+	texts := []string{}
+	for _, item := range response.Feed {
+		if item.Post.Author.DID == bskyDid {
+			texts = append(texts, item.Post.Record.Text)
+		}
+	}
+	return texts, nil
 }
 
 func GetStarterPacks(accessJwt string, endpoint string) ([]StarterPack, error) {
@@ -314,132 +372,53 @@ func GetStarterPacks(accessJwt string, endpoint string) ([]StarterPack, error) {
 	if err != nil {
 		return []StarterPack{}, err
 	}
-	fmt.Println("Getting starter packs for DID " + bskyDid)
+	LogDebug("Getting starter packs", Field("did", bskyDid))
 
+	client := NewAppViewClient(endpoint, accessJwt)
 	starterPacks := make([]StarterPack, 0)
-	hasMore := 0
-	counterArg := ""
-	for hasMore < 1 {
-		url := endpoint + "/xrpc/app.bsky.graph.getActorStarterPacks?limit=100&actor=" + url.QueryEscape(bskyDid) + counterArg
-
-		resp, err := SendGet(url, accessJwt)
-		if err != nil {
-			return []StarterPack{}, err
-		}
-
+	params := url.Values{"actor": {bskyDid}, "limit": {"100"}}
+	for {
 		var response StarterPackResponse
-		err = json.NewDecoder(resp.Body).Decode(&response)
+		err = client.Query("app.bsky.graph.getActorStarterPacks", params, &response)
 		if err != nil {
 			return []StarterPack{}, err
 		}
 		starterPacks = append(starterPacks, response.StarterPacks...)
 
-		if response.Cursor != "" {
-			counterArg = "&cursor=" + response.Cursor
-		} else {
-			hasMore = 1
+		if response.Cursor == "" {
+			break
 		}
+		params.Set("cursor", response.Cursor)
 	}
 
-	fmt.Printf("Got %d starter packs successfully\n", len(starterPacks))
+	LogDebug("Got starter packs successfully", Field("count", len(starterPacks)))
 	return starterPacks, nil
 }
 
 func AddUserToStarterPack(bskyHandle string, bskyDid string, starterPackTitle string, starterPackDescription string, starterPacks []StarterPack, accessJwt string, endpoint string) (string, error) {
-	fmt.Println("Adding users to the right starter pack (title: " + starterPackTitle + ", description: " + starterPackDescription + ")")
-	var starterPackUri string
-	var starterPackListUri string
-	var createdAt string
-	var err error
-	done := false
-	matchingStarterPacks := []StarterPack{}
-	for _, sp := range starterPacks {
-		if sp.Record.Name == starterPackTitle {
-			matchingStarterPacks = append(matchingStarterPacks, sp)
-		}
-	}
-	fmt.Println("Found " + fmt.Sprintf("%d", len(matchingStarterPacks)) + " matching starter packs")
-
-	if len(matchingStarterPacks) == 0 {
-		fmt.Println("No matching starter pack found with title: " + starterPackTitle)
-		return "", fmt.Errorf("No matching starter pack found with title: " + starterPackTitle)
-	}
-
-	for _, sp := range matchingStarterPacks {
-		list, err := GetList(sp.Record.List, accessJwt, endpoint)
-		if err != nil {
-			return "", err
-		}
-
-		fmt.Println("Found existing starter pack with title " + starterPackTitle + " and an item count of " + fmt.Sprintf("%d", list.ListItemCount))
-		userOnList, err := CheckOrDeleteUserOnList(sp.Record.List, bskyDid, false, accessJwt, endpoint)
-		if err != nil {
-			return "", fmt.Errorf("Error checking if user is on list: " + err.Error())
-		}
-		if userOnList {
-			fmt.Println("User is already on existing starter pack")
-			return sp.URI, nil
-		}
-		if list.ListItemCount < 149 {
-			fmt.Println("Found existing starter pack with title " + starterPackTitle + " and space left")
-			starterPackUri = sp.URI
-			starterPackListUri = sp.Record.List
-			starterPackDescription = sp.Record.Description
-			createdAt = sp.Record.CreatedAt
-			done = true
-			break
-		} else {
-			fmt.Println("Found existing starter pack with title " + starterPackTitle + " but it's full")
-		}
-	}
-
-	// we found matching starter packs but none of them had space left
-	if !done {
-		fmt.Println("Starter pack list is full, creating a new one")
-		timestamp := time.Now().Format("2006-01-02T15:04:05.000Z")
-		newListResponse, newStarterPackResponse, err := CreateStarterPack(starterPackTitle, starterPackDescription, timestamp, accessJwt, endpoint)
-		if err != nil {
-			return "", err
-		}
-		starterPackListUri = newListResponse.URI
-		starterPackUri = newStarterPackResponse.URI
-		createdAt = timestamp
-		fmt.Println("Created new list and starter pack")
-	}
-
-	err = AddUserToStarterPackList(bskyDid, starterPackListUri, starterPackUri, starterPackTitle, starterPackDescription, createdAt, accessJwt, endpoint)
+	timestamp := time.Now().Format("2006-01-02T15:04:05.000Z")
+	change := PlanStarterPackMembership(bskyDid, starterPackTitle, starterPackDescription, starterPacks, timestamp, accessJwt, endpoint)
+	result := ApplyMembershipChanges([]MembershipChange{change}, accessJwt, endpoint)
+	err := result.Err()
 	if err != nil {
 		return "", err
 	}
 
-	fmt.Println("Added users to the right starter pack")
-	return starterPackUri, nil
+	LogInfo("Added users to the right starter pack")
+	return result.Changes[0].URI, nil
 }
 
 func AddUserToList(bskyDid string, listTitle string, lists []List, accessJwt string, endpoint string) (string, error) {
-	fmt.Println("Adding users to the right list (title: " + listTitle + ")")
-	var listUri string
-	var err error
-	for _, list := range lists {
-		if list.Name == listTitle {
-			listUri = list.URI
-			fmt.Println("Found existing list with title " + listTitle)
-			break
-		}
-	}
-
-	if listUri == "" {
-		fmt.Println("No matching list found with title: " + listTitle)
-		return "", fmt.Errorf("No matching list found with title: " + listTitle)
-	}
-
-	err = AddUserToStandaloneList(bskyDid, listUri, accessJwt, endpoint)
+	timestamp := time.Now().Format("2006-01-02T15:04:05.000Z")
+	change := PlanListMembership(bskyDid, listTitle, lists, timestamp, accessJwt, endpoint)
+	result := ApplyMembershipChanges([]MembershipChange{change}, accessJwt, endpoint)
+	err := result.Err()
 	if err != nil {
 		return "", err
 	}
 
-	fmt.Println("Added users to the right list")
-	return listUri, nil
+	LogInfo("Added users to the right list")
+	return result.Changes[0].URI, nil
 }
 
 func CheckOrDeleteUserOnList(listUri string, userToCheckHandleOrDid string, deleteOnMatch bool, accessJwt string, endpoint string) (bool, error) {
@@ -447,64 +426,82 @@ func CheckOrDeleteUserOnList(listUri string, userToCheckHandleOrDid string, dele
 	if err != nil {
 		return false, fmt.Errorf("Error getting bsky_did: " + err.Error())
 	}
-	fmt.Println("Check if user " + userToCheckHandleOrDid + " is on list " + listUri + ". Delete on match? " + fmt.Sprintf("%t", deleteOnMatch))
-	hasMore := 0
-	counterArg := ""
-	for hasMore < 1 {
-		url := endpoint + "/xrpc/app.bsky.graph.getList?list=" + listUri + "&limit=100" + counterArg
-		resp, err := SendGet(url, accessJwt)
+	LogDebug("Checking if user is on list", Field("user", userToCheckHandleOrDid), Field("list", listUri), Field("deleteOnMatch", deleteOnMatch))
+
+	// the index only knows DIDs, handles are looked up in the list itself
+	if strings.HasPrefix(userToCheckHandleOrDid, "did:") {
+		rkey, indexed, onList, err := GetListIndexEntry(listUri, userToCheckHandleOrDid)
 		if err != nil {
 			return false, err
 		}
+		// a delete of a user missing in the index still looks at the list itself, otherwise a member the index missed
+		// would stay on the list
+		if indexed && !onList && !deleteOnMatch {
+			LogDebug("User is not on list according to the index", Field("user", userToCheckHandleOrDid), Field("list", listUri))
+			return false, nil
+		}
+		if indexed && onList {
+			LogDebug("User is on list according to the index", Field("user", userToCheckHandleOrDid), Field("list", listUri))
+			if deleteOnMatch {
+				LogInfo("Deleting user from list", Field("user", userToCheckHandleOrDid), Field("list", listUri))
+				err = RemoveUserFromList(bskyDid, "at://"+bskyDid+"/app.bsky.graph.listitem/"+rkey, accessJwt, endpoint)
+				if err != nil {
+					return true, err
+				}
+				updateListIndex(listUri, userToCheckHandleOrDid, "")
+			}
+			return true, nil
+		}
+	}
 
+	client := NewAppViewClient(endpoint, accessJwt)
+	params := url.Values{"list": {listUri}, "limit": {"100"}}
+	for {
 		var response ListResponse
-		err = json.NewDecoder(resp.Body).Decode(&response)
+		err = client.Query("app.bsky.graph.getList", params, &response)
 		if err != nil {
 			return false, err
 		}
 
 		for _, item := range response.Items {
 			if item.Subject.Handle == userToCheckHandleOrDid || item.Subject.DID == userToCheckHandleOrDid {
-				fmt.Println("User " + userToCheckHandleOrDid + " is on list " + listUri)
+				LogDebug("User is on list", Field("user", userToCheckHandleOrDid), Field("list", listUri))
 				if deleteOnMatch {
-					fmt.Println("Deleting user from list")
+					LogInfo("Deleting user from list", Field("user", userToCheckHandleOrDid), Field("list", listUri))
 					err = RemoveUserFromList(bskyDid, item.URI, accessJwt, endpoint)
 					if err != nil {
 						return true, err
 					}
+					updateListIndex(listUri, item.Subject.DID, "")
 				}
 				return true, nil
 			}
 		}
 
-		if response.Cursor != "" {
-			counterArg = "&cursor=" + response.Cursor
-		} else {
-			hasMore = 1
+		if response.Cursor == "" {
+			break
 		}
+		params.Set("cursor", response.Cursor)
 	}
 
-	fmt.Println("User " + userToCheckHandleOrDid + " is not on list " + listUri)
+	LogDebug("User is not on list", Field("user", userToCheckHandleOrDid), Field("list", listUri))
 	return false, nil
 }
 
 func RemoveUserFromList(bskyDid string, userUriToRemove string, accessJwt string, endpoint string) error {
-	fmt.Println("Removing user with uri " + userUriToRemove)
-	url := endpoint + "/xrpc/com.atproto.repo.deleteRecord"
+	LogInfo("Removing listitem", Field("uri", userUriToRemove))
 	rkey := userUriToRemove[strings.LastIndex(userUriToRemove, "/")+1:]
-	payload := "{ \"collection\":\"app.bsky.graph.listitem\", \"repo\":\"" + bskyDid + "\", \"rkey\":\"" + rkey + "\" }"
 
-	resp, err := SendPost(url, payload, accessJwt)
+	err := NewXrpcClient(endpoint, accessJwt).Procedure("com.atproto.repo.deleteRecord", DeleteRecordRequest{Repo: bskyDid, Collection: "app.bsky.graph.listitem", Rkey: rkey}, nil)
 	if err != nil {
-		return err
-	}
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("Error removing user from list, status code: " + fmt.Sprintf("%d", resp.StatusCode))
+		return fmt.Errorf("Error removing user from list: " + err.Error())
 	}
 	return nil
 }
 
 func CreateAllStarterPacksAndLists(naming Naming, accessJwt string, endpoint string) (string, error) {
+	defer ReserveForHeavyOperation(endpoint)()
+
 	starterPacks, err := GetStarterPacks(accessJwt, endpoint)
 	if err != nil {
 		return "", err
@@ -516,9 +513,9 @@ func CreateAllStarterPacksAndLists(naming Naming, accessJwt string, endpoint str
 	}
 
 	if CheckIfStarterPackExists(naming.Title, starterPacks) {
-		fmt.Println("Starter pack with title " + naming.Title + " already exists")
+		LogInfo("Starter pack already exists", Field("title", naming.Title))
 	} else {
-		fmt.Println("Creating starter pack with title " + naming.Title + " and description " + naming.Description)
+		LogInfo("Creating starter pack", Field("title", naming.Title), Field("description", naming.Description))
 		_, _, err := CreateStarterPack(naming.Title, naming.Description, time.Now().Format("2006-01-02T15:04:05.000Z"), accessJwt, endpoint)
 		if err != nil {
 			return "", err
@@ -526,9 +523,9 @@ func CreateAllStarterPacksAndLists(naming Naming, accessJwt string, endpoint str
 	}
 
 	if CheckIfListExists(naming.Title, lists) {
-		fmt.Println("List with title " + naming.Title + " already exists")
+		LogInfo("List already exists", Field("title", naming.Title))
 	} else {
-		fmt.Println("Creating List with title " + naming.Title + " and description " + naming.Description)
+		LogInfo("Creating list", Field("title", naming.Title), Field("description", naming.Description))
 		_, err := CreateList(naming.Title, naming.Description, accessJwt, endpoint)
 		if err != nil {
 			return "", err
@@ -537,18 +534,18 @@ func CreateAllStarterPacksAndLists(naming Naming, accessJwt string, endpoint str
 
 	for first, secondArray := range naming.FirstAndSecondLevel {
 		if CheckIfStarterPackExists(first.Title, starterPacks) {
-			fmt.Println("Starter pack with title " + first.Title + " already exists")
+			LogInfo("Starter pack already exists", Field("title", first.Title))
 		} else {
-			fmt.Println("Creating starter pack with title " + first.Title + " and description " + first.Description)
+			LogInfo("Creating starter pack", Field("title", first.Title), Field("description", first.Description))
 			_, _, err := CreateStarterPack(first.Title, first.Description, time.Now().Format("2006-01-02T15:04:05.000Z"), accessJwt, endpoint)
 			if err != nil {
 				return "", err
 			}
 		}
 		if CheckIfListExists(first.Title, lists) {
-			fmt.Println("List with title " + first.Title + " already exists")
+			LogInfo("List already exists", Field("title", first.Title))
 		} else {
-			fmt.Println("Creating List with title " + first.Title + " and description " + first.Description)
+			LogInfo("Creating list", Field("title", first.Title), Field("description", first.Description))
 			_, err := CreateList(first.Title, first.Description, accessJwt, endpoint)
 			if err != nil {
 				return "", err
@@ -556,18 +553,18 @@ func CreateAllStarterPacksAndLists(naming Naming, accessJwt string, endpoint str
 		}
 		for _, second := range secondArray {
 			if CheckIfStarterPackExists(second.Title, starterPacks) {
-				fmt.Println("Starter pack with title " + second.Title + " already exists")
+				LogInfo("Starter pack already exists", Field("title", second.Title))
 			} else {
-				fmt.Println("Creating starter pack with title " + second.Title + " and description " + second.Description)
+				LogInfo("Creating starter pack", Field("title", second.Title), Field("description", second.Description))
 				_, _, err = CreateStarterPack(second.Title, second.Description, time.Now().Format("2006-01-02T15:04:05.000Z"), accessJwt, endpoint)
 				if err != nil {
 					return "", err
 				}
 			}
 			if CheckIfListExists(second.Title, lists) {
-				fmt.Println("List with title " + second.Title + " already exists")
+				LogInfo("List already exists", Field("title", second.Title))
 			} else {
-				fmt.Println("Creating List with title " + second.Title + " and description " + second.Description)
+				LogInfo("Creating list", Field("title", second.Title), Field("description", second.Description))
 				_, err := CreateList(second.Title, second.Description, accessJwt, endpoint)
 				if err != nil {
 					return "", err
@@ -598,20 +595,15 @@ func CheckIfListExists(listTitle string, lists []List) bool {
 }
 
 func GetList(listUri string, accessJwt string, endpoint string) (List, error) {
-	fmt.Println("Getting list for URI " + listUri)
-	url := endpoint + "/xrpc/app.bsky.graph.getList?list=" + listUri
-	resp, err := SendGet(url, accessJwt)
-	if err != nil {
-		return List{}, err
-	}
+	LogDebug("Getting list", Field("list", listUri))
 
 	var response ListResponse
-	err = json.NewDecoder(resp.Body).Decode(&response)
+	err := NewAppViewClient(endpoint, accessJwt).Query("app.bsky.graph.getList", url.Values{"list": {listUri}}, &response)
 	if err != nil {
 		return List{}, err
 	}
 
-	fmt.Println("Got list successfully")
+	LogDebug("Got list successfully", Field("list", listUri))
 	return response.List, nil
 }
 
@@ -620,116 +612,147 @@ func GetLists(accessJwt string, endpoint string) ([]List, error) {
 	if err != nil {
 		return []List{}, err
 	}
-	fmt.Println("Getting lists for DID " + bskyDid)
+	LogDebug("Getting lists", Field("did", bskyDid))
 
+	client := NewAppViewClient(endpoint, accessJwt)
 	lists := make([]List, 0)
-	hasMore := 0
-	counterArg := ""
-	for hasMore < 1 {
-		url := endpoint + "/xrpc/app.bsky.graph.getLists?limit=100&actor=" + url.QueryEscape(bskyDid) + counterArg
-
-		resp, err := SendGet(url, accessJwt)
-		if err != nil {
-			return []List{}, err
-		}
-
+	params := url.Values{"actor": {bskyDid}, "limit": {"100"}}
+	for {
 		var response ListsResponse
-		err = json.NewDecoder(resp.Body).Decode(&response)
+		err = client.Query("app.bsky.graph.getLists", params, &response)
 		if err != nil {
 			return []List{}, err
 		}
 		lists = append(lists, response.Lists...)
 
-		if response.Cursor != "" {
-			counterArg = "&cursor=" + response.Cursor
-		} else {
-			hasMore = 1
+		if response.Cursor == "" {
+			break
 		}
+		params.Set("cursor", response.Cursor)
 	}
 
-	fmt.Printf("Got %d lists successfully\n", len(lists))
+	LogDebug("Got lists successfully", Field("count", len(lists)))
 	return lists, nil
 }
 
 func CreateList(listTitle string, listDescription string, accessJwt string, endpoint string) (CreateRecordResponse, error) {
-	fmt.Println("Creating list with title " + listTitle + " and description " + listDescription)
+	LogInfo("Creating list", Field("title", listTitle), Field("description", listDescription))
 	bskyDid, err := variables.Get("bsky_did")
 	if err != nil {
 		return CreateRecordResponse{}, err
 	}
 
-	url := endpoint + "/xrpc/com.atproto.repo.createRecord"
-
-	payload := "{\"collection\": \"app.bsky.graph.list\",\"repo\": \"" + bskyDid + "\",\"record\": {\"name\": \"" + listTitle + "\",\"description\": \"" + listDescription + "\",\"createdAt\": \"" + time.Now().Format("2006-01-02T15:04:05.000Z") + "\",\"purpose\": \"app.bsky.graph.defs#curatelist\",\"$type\": \"app.bsky.graph.list\"}}"
-
-	fmt.Println("Creating list")
-	resp, err := SendPost(url, payload, accessJwt)
+	request := CreateRecordRequest{
+		Repo:       bskyDid,
+		Collection: "app.bsky.graph.list",
+		Record: ListRecord{
+			Type:        "app.bsky.graph.list",
+			Name:        listTitle,
+			Description: listDescription,
+			Purpose:     "app.bsky.graph.defs#curatelist",
+			CreatedAt:   time.Now().Format("2006-01-02T15:04:05.000Z"),
+		},
+	}
 
+	LogDebug("Creating list record")
 	var listResponse CreateRecordResponse
-	err = json.NewDecoder(resp.Body).Decode(&listResponse)
+	err = NewXrpcClient(endpoint, accessJwt).Procedure("com.atproto.repo.createRecord", request, &listResponse)
 	if err != nil {
 		return CreateRecordResponse{}, err
 	}
 	if (listResponse == CreateRecordResponse{}) {
 		return CreateRecordResponse{}, fmt.Errorf("Error creating list, couldn't parse JSON")
 	}
+	InitListIndex(listResponse.URI)
 
 	return listResponse, nil
 }
 
 func AddUserToStandaloneList(userToAddDid string, listUri string, accessJwt string, endpoint string) error {
-	fmt.Println("Adding user " + userToAddDid + " to list with URI " + listUri)
+	LogInfo("Adding user to list", Field("did", userToAddDid), Field("list", listUri))
 	bskyDid, err := variables.Get("bsky_did")
 	if err != nil {
 		return err
 	}
 
-	url := endpoint + "/xrpc/com.atproto.repo.createRecord"
+	userOnList, err := CheckOrDeleteUserOnList(listUri, userToAddDid, false, accessJwt, endpoint)
+	if err != nil {
+		return fmt.Errorf("Error checking if user is on list: " + err.Error())
+	}
+	if userOnList {
+		LogInfo("User is already on list, not adding again", Field("did", userToAddDid), Field("list", listUri))
+		return nil
+	}
 
-	payload := "{\"collection\":\"app.bsky.graph.listitem\",\"repo\":\"" + bskyDid + "\",\"record\":{\"subject\":\"" + userToAddDid + "\",\"list\":\"" + listUri + "\",\"createdAt\":\"" + time.Now().Format("2006-01-02T15:04:05.000Z") + "\",\"$type\":\"app.bsky.graph.listitem\"}}"
+	request := CreateRecordRequest{
+		Repo:       bskyDid,
+		Collection: "app.bsky.graph.listitem",
+		Record: ListItemRecord{
+			Type:      "app.bsky.graph.listitem",
+			Subject:   userToAddDid,
+			List:      listUri,
+			CreatedAt: time.Now().Format("2006-01-02T15:04:05.000Z"),
+		},
+	}
 
-	_, err = SendPost(url, payload, accessJwt)
+	var response CreateRecordResponse
+	err = NewXrpcClient(endpoint, accessJwt).Procedure("com.atproto.repo.createRecord", request, &response)
 	if err != nil {
 		return err
 	}
+	updateListIndex(listUri, userToAddDid, response.URI[strings.LastIndex(response.URI, "/")+1:])
 
-	fmt.Println("Added user to list successfully")
+	LogInfo("Added user to list successfully", Field("did", userToAddDid), Field("list", listUri))
 	return nil
 }
 
 func CreateStarterPack(starterPackTitle string, starterPackDescription string, createdAt string, accessJwt string, endpoint string) (CreateRecordResponse, CreateRecordResponse, error) {
-	fmt.Println("Creating starter pack with title " + starterPackTitle + " and description " + starterPackDescription)
+	LogInfo("Creating starter pack", Field("title", starterPackTitle), Field("description", starterPackDescription))
 	bskyDid, err := variables.Get("bsky_did")
 	if err != nil {
 		return CreateRecordResponse{}, CreateRecordResponse{}, err
 	}
 
-	url := endpoint + "/xrpc/com.atproto.repo.createRecord"
-
-	payload := "{\"collection\": \"app.bsky.graph.list\",\"repo\": \"" + bskyDid + "\",\"record\": {\"name\": \"" + starterPackTitle + "\",\"description\": \"" + starterPackDescription + "\",\"createdAt\": \"" + createdAt + "\",\"purpose\": \"app.bsky.graph.defs#referencelist\",\"$type\": \"app.bsky.graph.list\"}}"
-
-	fmt.Println("Creating list for starter pack")
-	resp, err := SendPost(url, payload, accessJwt)
+	client := NewXrpcClient(endpoint, accessJwt)
+	request := CreateRecordRequest{
+		Repo:       bskyDid,
+		Collection: "app.bsky.graph.list",
+		Record: ListRecord{
+			Type:        "app.bsky.graph.list",
+			Name:        starterPackTitle,
+			Description: starterPackDescription,
+			Purpose:     "app.bsky.graph.defs#referencelist",
+			CreatedAt:   createdAt,
+		},
+	}
 
+	LogDebug("Creating list for starter pack", Field("title", starterPackTitle))
 	var listResponse CreateRecordResponse
-	err = json.NewDecoder(resp.Body).Decode(&listResponse)
+	err = client.Procedure("com.atproto.repo.createRecord", request, &listResponse)
 	if err != nil {
 		return CreateRecordResponse{}, CreateRecordResponse{}, err
 	}
 	if (listResponse == CreateRecordResponse{}) {
 		return CreateRecordResponse{}, CreateRecordResponse{}, fmt.Errorf("Error creating list for starter pack, couldn't parse JSON")
 	}
+	InitListIndex(listResponse.URI)
 
-	payload = "{\"collection\": \"app.bsky.graph.starterpack\",\"repo\": \"" + bskyDid + "\",\"record\": {\"name\": \"" + starterPackTitle + "\",\"description\": \"" + starterPackDescription + "\",\"list\": \"" + listResponse.URI + "\",\"feeds\": [],\"createdAt\": \"" + createdAt + "\",\"$type\": \"app.bsky.graph.starterpack\"}}"
-
-	fmt.Println("Making list a starter pack")
-	resp, err = SendPost(url, payload, accessJwt)
-	if err != nil {
-		return CreateRecordResponse{}, CreateRecordResponse{}, err
+	request = CreateRecordRequest{
+		Repo:       bskyDid,
+		Collection: "app.bsky.graph.starterpack",
+		Record: StarterPackRecord{
+			Type:        "app.bsky.graph.starterpack",
+			Name:        starterPackTitle,
+			Description: starterPackDescription,
+			List:        listResponse.URI,
+			Feeds:       []interface{}{},
+			CreatedAt:   createdAt,
+		},
 	}
 
+	LogDebug("Making list a starter pack", Field("title", starterPackTitle))
 	var starterPackResponse CreateRecordResponse
-	err = json.NewDecoder(resp.Body).Decode(&starterPackResponse)
+	err = client.Procedure("com.atproto.repo.createRecord", request, &starterPackResponse)
 	if err != nil {
 		return CreateRecordResponse{}, CreateRecordResponse{}, err
 	}
@@ -737,27 +760,51 @@ func CreateStarterPack(starterPackTitle string, starterPackDescription string, c
 		return CreateRecordResponse{}, CreateRecordResponse{}, fmt.Errorf("Error creating list for starter pack, couldn't parse JSON")
 	}
 
-	fmt.Println("Created starter pack successfully at " + starterPackResponse.URI + " pointing to list at " + listResponse.URI)
+	LogInfo("Created starter pack successfully", Field("starterPack", starterPackResponse.URI), Field("list", listResponse.URI))
 	return listResponse, starterPackResponse, nil
 }
 
 func AddUserToStarterPackList(userToAddDid string, listUri string, starterPackUri string, starterPackTitle string, starterPackDescription string, createdAt string, accessJwt string, endpoint string) error {
-	fmt.Println("Adding user " + userToAddDid + " to list with URI " + listUri + " and starter pack with URI " + starterPackUri)
+	LogInfo("Adding user to starter pack", Field("did", userToAddDid), Field("list", listUri), Field("starterPack", starterPackUri))
 	bskyDid, err := variables.Get("bsky_did")
 	if err != nil {
 		return err
 	}
 
-	url := endpoint + "/xrpc/com.atproto.repo.applyWrites"
+	userOnList, err := CheckOrDeleteUserOnList(listUri, userToAddDid, false, accessJwt, endpoint)
+	if err != nil {
+		return fmt.Errorf("Error checking if user is on list: " + err.Error())
+	}
+	if userOnList {
+		LogInfo("User is already on starter pack list, not adding again", Field("did", userToAddDid), Field("starterPack", starterPackUri))
+		return nil
+	}
+
 	now := time.Now()
 	timestamp := now.Format("2006-01-02T15:04:05.000Z")
 
-	payload := "{\"repo\": \"" + bskyDid + "\",\"writes\": [{\"$type\": \"com.atproto.repo.applyWrites#create\",\"collection\": \"app.bsky.graph.listitem\",\"value\": {\"$type\": \"app.bsky.graph.listitem\",\"subject\": \"" + userToAddDid + "\",\"list\": \"" + listUri + "\",\"createdAt\": \"" + timestamp + "\"}}]}"
+	request := ApplyWritesRequest{
+		Repo: bskyDid,
+		Writes: []ApplyWritesWrite{{
+			Type:       "com.atproto.repo.applyWrites#create",
+			Collection: "app.bsky.graph.listitem",
+			Value: ListItemRecord{
+				Type:      "app.bsky.graph.listitem",
+				Subject:   userToAddDid,
+				List:      listUri,
+				CreatedAt: timestamp,
+			},
+		}},
+	}
 
-	_, err = SendPost(url, payload, accessJwt)
+	var response ApplyWritesResponse
+	err = NewXrpcClient(endpoint, accessJwt).Procedure("com.atproto.repo.applyWrites", request, &response)
 	if err != nil {
 		return err
 	}
+	if len(response.Results) > 0 {
+		updateListIndex(listUri, userToAddDid, response.Results[0].URI[strings.LastIndex(response.Results[0].URI, "/")+1:])
+	}
 
 	err = PutRecordForStarterPack(bskyDid, starterPackUri, starterPackDescription, starterPackTitle, createdAt, listUri, timestamp, accessJwt, endpoint)
 
@@ -765,86 +812,101 @@ func AddUserToStarterPackList(userToAddDid string, listUri string, starterPackUr
 		return err
 	}
 
-	fmt.Println("Added user to list successfully")
+	LogInfo("Added user to list successfully", Field("did", userToAddDid), Field("list", listUri))
 	return nil
 }
 
 func PutRecordForStarterPack(bskyDid string, starterPackUri string, starterPackDescription string, starterPackTitle string, createdAt string, listUri string, timestamp string, accessJwt string, endpoint string) error {
-	url := endpoint + "/xrpc/com.atproto.repo.putRecord"
 	rkey := starterPackUri[strings.LastIndex(starterPackUri, "/")+1:]
 
-	payload := "{\"repo\": \"" + bskyDid + "\",\"collection\": \"app.bsky.graph.starterpack\",\"rkey\": \"" + rkey + "\",\"record\": {\"name\": \"" + starterPackTitle + "\",\"description\": \"" + starterPackDescription + "\",\"list\": \"" + listUri + "\",\"feeds\": [],\"createdAt\": \"" + createdAt + "\",\"updatedAt\": \"" + timestamp + "\"}}"
-
-	_, err := SendPost(url, payload, accessJwt)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	request := PutRecordRequest{
+		Repo:       bskyDid,
+		Collection: "app.bsky.graph.starterpack",
+		Rkey:       rkey,
+		Record: StarterPackRecord{
+			Type:        "app.bsky.graph.starterpack",
+			Name:        starterPackTitle,
+			Description: starterPackDescription,
+			List:        listUri,
+			Feeds:       []interface{}{},
+			CreatedAt:   createdAt,
+			UpdatedAt:   timestamp,
+		},
+	}
+
+	return NewXrpcClient(endpoint, accessJwt).Procedure("com.atproto.repo.putRecord", request, nil)
 }
 
 func DeleteStarterPack(rkey string, accessJwt string, endpoint string) (string, error) {
-	fmt.Println("Deleting starter pack with rkey " + rkey)
+	LogInfo("Deleting starter pack", Field("rkey", rkey))
 	bskyDid, err := variables.Get("bsky_did")
 	if err != nil {
 		return "", err
 	}
 
-	url := endpoint + "/xrpc/com.atproto.repo.deleteRecord"
-
-	payload := "{\"repo\": \"" + bskyDid + "\",\"collection\": \"app.bsky.graph.starterpack\",\"rkey\": \"" + rkey + "\"}"
-
-	_, err = SendPost(url, payload, accessJwt)
+	err = NewXrpcClient(endpoint, accessJwt).Procedure("com.atproto.repo.deleteRecord", DeleteRecordRequest{Repo: bskyDid, Collection: "app.bsky.graph.starterpack", Rkey: rkey}, nil)
 	if err != nil {
 		return "", err
 	}
 
-	fmt.Println("Deleted starter pack successfully")
+	LogInfo("Deleted starter pack successfully", Field("rkey", rkey))
 	return "Deleted starter pack successfully", nil
 }
 
 func DeleteList(rkey string, accessJwt string, endpoint string) (string, error) {
-	fmt.Println("Deleting list with rkey " + rkey)
+	LogInfo("Deleting list", Field("rkey", rkey))
 	bskyDid, err := variables.Get("bsky_did")
 	if err != nil {
 		return "", err
 	}
 
-	url := endpoint + "/xrpc/com.atproto.repo.applyWrites"
-
-	payload := "{\"repo\": \"" + bskyDid + "\",\"writes\": [{\"$type\": \"com.atproto.repo.applyWrites#delete\",\"collection\": \"app.bsky.graph.list\",\"rkey\": \"" + rkey + "\"}]}"
+	request := ApplyWritesRequest{
+		Repo: bskyDid,
+		Writes: []ApplyWritesWrite{{
+			Type:       "com.atproto.repo.applyWrites#delete",
+			Collection: "app.bsky.graph.list",
+			Rkey:       rkey,
+		}},
+	}
 
-	_, err = SendPost(url, payload, accessJwt)
+	err = NewXrpcClient(endpoint, accessJwt).Procedure("com.atproto.repo.applyWrites", request, nil)
 	if err != nil {
 		return "", err
 	}
 
-	fmt.Println("Deleted list successfully")
+	deleteListIndex("at://" + bskyDid + "/app.bsky.graph.list/" + rkey)
+	LogInfo("Deleted list successfully", Field("rkey", rkey))
 	return "Deleted list successfully", nil
 }
 
 func Follow(toFollowDid string, accessJwt string, endpoint string) (string, error) {
-	fmt.Println("Following user with DID " + toFollowDid)
+	LogInfo("Following user", Field("did", toFollowDid))
 	bskyDid, err := variables.Get("bsky_did")
 	if err != nil {
 		return "", err
 	}
 
-	url := endpoint + "/xrpc/com.atproto.repo.createRecord"
-
-	payload := "{\"collection\": \"app.bsky.graph.follow\",\"repo\": \"" + bskyDid + "\",\"record\": {\"subject\": \"" + toFollowDid + "\",\"createdAt\": \"" + time.Now().Format("2006-01-02T15:04:05.000Z") + "\",\"$type\": \"app.bsky.graph.follow\"}}"
+	request := CreateRecordRequest{
+		Repo:       bskyDid,
+		Collection: "app.bsky.graph.follow",
+		Record: FollowRecord{
+			Type:      "app.bsky.graph.follow",
+			Subject:   toFollowDid,
+			CreatedAt: time.Now().Format("2006-01-02T15:04:05.000Z"),
+		},
+	}
 
-	_, err = SendPost(url, payload, accessJwt)
+	err = NewXrpcClient(endpoint, accessJwt).Procedure("com.atproto.repo.createRecord", request, nil)
 	if err != nil {
 		return "", err
 	}
 
-	fmt.Println("Followed user successfully")
+	LogInfo("Followed user successfully", Field("did", toFollowDid))
 	return "Followed user successfully", nil
 }
 
 func SendDirectMessage(targetHandle string, message string, accessJwt string, endpoint string) error {
-	fmt.Println("Sending direct message to " + targetHandle)
+	LogInfo("Sending direct message", Field("handle", targetHandle))
 
 	// Get target user's profile to get their DID
 	targetProfile, err := GetProfile(targetHandle, accessJwt, endpoint)
@@ -852,45 +914,30 @@ func SendDirectMessage(targetHandle string, message string, accessJwt string, en
 		return fmt.Errorf("error getting profile for %s: %v", targetHandle, err)
 	}
 
-	// Try to send via chat API
-	sendUrl := "https://api.bsky.chat/xrpc/chat.bsky.convo.sendMessage"
+	chatClient := NewXrpcClient(ChatUrl(), accessJwt)
 
 	// First try to get or create a conversation
-	convoUrl := "https://api.bsky.chat/xrpc/chat.bsky.convo.getConvoForMembers?members=" + url.QueryEscape(targetProfile.DID)
-
-	convoResp, err := SendGet(convoUrl, accessJwt)
+	var convoResponse ConvoResponse
+	err = chatClient.Query("chat.bsky.convo.getConvoForMembers", url.Values{"members": {targetProfile.DID}}, &convoResponse)
 	if err != nil {
 		return fmt.Errorf("chat API not available or conversation creation failed: %v", err)
 	}
-	defer convoResp.Body.Close()
-
-	// Parse conversation response to get convoId
-	var convoData map[string]interface{}
-	err = json.NewDecoder(convoResp.Body).Decode(&convoData)
-	if err != nil {
-		return fmt.Errorf("error parsing conversation response: %v", err)
-	}
-
-	convoId, ok := convoData["convo"].(map[string]interface{})["id"].(string)
-	if !ok {
+	if convoResponse.Convo.ID == "" {
 		return fmt.Errorf("no conversation ID found in response")
 	}
 
 	// Send the direct message
-	payload := fmt.Sprintf(`{
-		"convoId": "%s",
-		"message": {
-			"text": "%s",
-			"$type": "chat.bsky.convo.defs#messageInput"
-		}
-	}`, convoId, message)
+	request := SendMessageRequest{
+		ConvoId: convoResponse.Convo.ID,
+		Message: MessageInput{Type: "chat.bsky.convo.defs#messageInput", Text: message},
+	}
 
-	_, err = SendPost(sendUrl, payload, accessJwt)
+	err = chatClient.Procedure("chat.bsky.convo.sendMessage", request, nil)
 	if err != nil {
 		return fmt.Errorf("error sending direct message via chat API: %v", err)
 	}
 
-	fmt.Println("Direct message sent successfully via chat API")
+	LogInfo("Direct message sent successfully via chat API", Field("handle", targetHandle))
 	return nil
 }
 
@@ -974,8 +1021,15 @@ func SetupAllStarterPacksAndLists(moduleSpecifics ModuleSpecifics, w http.Respon
 	return nil
 }
 
-func SetLabel(label string, targetHandle string, accessJwt string, endpoint string) error {
-	fmt.Println("Adding label " + label + " to handle " + targetHandle)
+// SetLabel adds the label to the user through Ozone or, if labeler_mode is builtin, the built-in labeler
+func SetLabel(label string, targetHandleOrDid string, accessJwt string, endpoint string) error {
+	return SetLabelWithExpiry(label, targetHandleOrDid, time.Time{}, accessJwt, endpoint)
+}
+
+// SetLabelWithExpiry adds a label that expires at the given time, a zero time means it never expires. If the user
+// already has the label but it expires more than LabelRenewalInterval earlier, it is renewed
+func SetLabelWithExpiry(label string, targetHandleOrDid string, exp time.Time, accessJwt string, endpoint string) error {
+	LogInfo("Adding label", Field("label", label), Field("target", targetHandleOrDid))
 	bskyDid, err := variables.Get("bsky_did")
 	if err != nil {
 		return err
@@ -986,61 +1040,68 @@ func SetLabel(label string, targetHandle string, accessJwt string, endpoint stri
 		return err
 	}
 
-	targetProfile, err := GetProfile(targetHandle, accessJwt, endpoint)
+	targetDid, err := ResolveDid(targetHandleOrDid, accessJwt, endpoint)
 	if err != nil {
 		return err
 	}
 
-	additionalHeaders := map[string]string{"atproto-accept-labelers": bskyLabelerDid + ";redact", "atproto-proxy": bskyDid + "#atproto_labeler"}
-
-	url := endpoint + "/xrpc/tools.ozone.moderation.getRepo?did=" + url.QueryEscape(targetProfile.DID)
-
-	resp, err := SendGetWithHeader(url, accessJwt, additionalHeaders)
-	if err != nil {
-		return err
+	if LabelerMode() == LabelerModeBuiltin {
+		return emitBuiltinLabel(targetDid, label, false, exp)
 	}
 
+	client := NewXrpcClient(endpoint, accessJwt).WithHeaders(map[string]string{"atproto-accept-labelers": bskyLabelerDid + ";redact", "atproto-proxy": bskyDid + "#atproto_labeler"})
+
 	var response ModerationRepoResponse
-	err = json.NewDecoder(resp.Body).Decode(&response)
+	err = client.Query("tools.ozone.moderation.getRepo", url.Values{"did": {targetDid}}, &response)
 	if err != nil {
 		return err
 	}
 
 	found := false
 	for _, existingLabel := range response.Labels {
-		if existingLabel.Val == label {
+		if existingLabel.Val == label && labelIsCurrent(existingLabel.Exp, exp) {
 			found = true
 			break
 		}
 	}
 
 	if found {
-		fmt.Println("Label already exists")
+		LogInfo("Label already exists", Field("label", label), Field("target", targetHandleOrDid))
 		return nil
 	} else {
-		url = endpoint + "/xrpc/tools.ozone.moderation.emitEvent"
+		subject := RepoRef{Type: "com.atproto.admin.defs#repoRef", Did: targetDid}
 
-		payload := "{\"subject\": {\"$type\": \"com.atproto.admin.defs#repoRef\",\"did\": \"" + targetProfile.DID + "\"},\"createdBy\": \"" + bskyDid + "\",\"subjectBlobCids\": [],\"event\": {\"$type\": \"tools.ozone.moderation.defs#modEventLabel\",\"createLabelVals\": [\"" + label + "\"],\"negateLabelVals\": []}}"
+		event := ModEventLabel{Type: "tools.ozone.moderation.defs#modEventLabel", CreateLabelVals: []string{label}, NegateLabelVals: []string{}}
+		if !exp.IsZero() {
+			event.DurationInHours = int(math.Ceil(time.Until(exp).Hours()))
+		}
+		request := EmitEventRequest{
+			Subject:         subject,
+			CreatedBy:       bskyDid,
+			SubjectBlobCids: []string{},
+			Event:           event,
+		}
 
-		_, err = SendPostWithHeaders(url, payload, accessJwt, additionalHeaders)
+		err = client.Procedure("tools.ozone.moderation.emitEvent", request, nil)
 		if err != nil {
 			return err
 		}
 
-		payload = "{\"subject\": {\"$type\": \"com.atproto.admin.defs#repoRef\",\"did\": \"" + targetProfile.DID + "\"},\"createdBy\": \"" + bskyDid + "\",\"subjectBlobCids\": [],\"event\": {\"$type\": \"tools.ozone.moderation.defs#modEventAcknowledge\"}}"
+		request.Event = ModEventAcknowledge{Type: "tools.ozone.moderation.defs#modEventAcknowledge"}
 
-		_, err = SendPostWithHeaders(url, payload, accessJwt, additionalHeaders)
+		err = client.Procedure("tools.ozone.moderation.emitEvent", request, nil)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println("Label added successfully")
+		LogInfo("Label added successfully", Field("label", label), Field("target", targetHandleOrDid))
 		return nil
 	}
 }
 
-func RemoveLabel(label string, targetHandle string, accessJwt string, endpoint string) error {
-	fmt.Println("Removing label " + label + " from handle " + targetHandle)
+// RemoveLabel negates the label of the user through Ozone or, if labeler_mode is builtin, the built-in labeler
+func RemoveLabel(label string, targetHandleOrDid string, accessJwt string, endpoint string) error {
+	LogInfo("Removing label", Field("label", label), Field("target", targetHandleOrDid))
 	bskyDid, err := variables.Get("bsky_did")
 	if err != nil {
 		return err
@@ -1051,22 +1112,19 @@ func RemoveLabel(label string, targetHandle string, accessJwt string, endpoint s
 		return err
 	}
 
-	targetProfile, err := GetProfile(targetHandle, accessJwt, endpoint)
+	targetDid, err := ResolveDid(targetHandleOrDid, accessJwt, endpoint)
 	if err != nil {
 		return err
 	}
 
-	additionalHeaders := map[string]string{"atproto-accept-labelers": bskyLabelerDid + ";redact", "atproto-proxy": bskyDid + "#atproto_labeler"}
-
-	requestURL := endpoint + "/xrpc/tools.ozone.moderation.getRepo?did=" + url.QueryEscape(targetProfile.DID)
-
-	resp, err := SendGetWithHeader(requestURL, accessJwt, additionalHeaders)
-	if err != nil {
-		return err
+	if LabelerMode() == LabelerModeBuiltin {
+		return emitBuiltinLabel(targetDid, label, true, time.Time{})
 	}
 
+	client := NewXrpcClient(endpoint, accessJwt).WithHeaders(map[string]string{"atproto-accept-labelers": bskyLabelerDid + ";redact", "atproto-proxy": bskyDid + "#atproto_labeler"})
+
 	var response ModerationRepoResponse
-	err = json.NewDecoder(resp.Body).Decode(&response)
+	err = client.Query("tools.ozone.moderation.getRepo", url.Values{"did": {targetDid}}, &response)
 	if err != nil {
 		return err
 	}
@@ -1080,26 +1138,31 @@ func RemoveLabel(label string, targetHandle string, accessJwt string, endpoint s
 	}
 
 	if !found {
-		fmt.Println("Label does not exist")
+		LogInfo("Label does not exist", Field("label", label), Field("target", targetHandleOrDid))
 		return nil
 	} else {
-		requestURL = endpoint + "/xrpc/tools.ozone.moderation.emitEvent"
+		subject := RepoRef{Type: "com.atproto.admin.defs#repoRef", Did: targetDid}
 
-		payload := "{\"subject\": {\"$type\": \"com.atproto.admin.defs#repoRef\",\"did\": \"" + targetProfile.DID + "\"},\"createdBy\": \"" + bskyDid + "\",\"subjectBlobCids\": [],\"event\": {\"$type\": \"tools.ozone.moderation.defs#modEventLabel\",\"createLabelVals\": [],\"negateLabelVals\": [\"" + label + "\"]}}"
+		request := EmitEventRequest{
+			Subject:         subject,
+			CreatedBy:       bskyDid,
+			SubjectBlobCids: []string{},
+			Event:           ModEventLabel{Type: "tools.ozone.moderation.defs#modEventLabel", CreateLabelVals: []string{}, NegateLabelVals: []string{label}},
+		}
 
-		_, err = SendPostWithHeaders(requestURL, payload, accessJwt, additionalHeaders)
+		err = client.Procedure("tools.ozone.moderation.emitEvent", request, nil)
 		if err != nil {
 			return err
 		}
 
-		payload = "{\"subject\": {\"$type\": \"com.atproto.admin.defs#repoRef\",\"did\": \"" + targetProfile.DID + "\"},\"createdBy\": \"" + bskyDid + "\",\"subjectBlobCids\": [],\"event\": {\"$type\": \"tools.ozone.moderation.defs#modEventAcknowledge\"}}"
+		request.Event = ModEventAcknowledge{Type: "tools.ozone.moderation.defs#modEventAcknowledge"}
 
-		_, err = SendPostWithHeaders(requestURL, payload, accessJwt, additionalHeaders)
+		err = client.Procedure("tools.ozone.moderation.emitEvent", request, nil)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println("Label removed successfully")
+		LogInfo("Label removed successfully", Field("label", label), Field("target", targetHandleOrDid))
 		return nil
 	}
 }
@@ -1109,16 +1172,11 @@ func SendPost(url string, payload string, accessJwt string) (*http.Response, err
 }
 
 func SendPostWithHeaders(url string, payload string, accessJwt string, additionalHeaders map[string]string) (*http.Response, error) {
-	fmt.Println("Sending POST request to " + url)
-	// check if url constains login
-	if strings.Contains(url, "createSession") {
-		fmt.Println("Not logging the payload for login")
-	} else {
-		fmt.Println("Payload: " + payload)
-	}
+	LogInfo("Sending POST request", Field("url", RedactUrl(url)))
+	LogDebug("POST payload", Field("url", RedactUrl(url)), Field("payload", RedactPayload(payload)))
 	request, err := http.NewRequest("POST", url, strings.NewReader(payload))
 	if err != nil {
-		fmt.Println("Error creating POST request: " + err.Error())
+		LogError("Error creating POST request", Field("error", err))
 		return nil, err
 	}
 
@@ -1130,20 +1188,13 @@ func SendPostWithHeaders(url string, payload string, accessJwt string, additiona
 	}
 	request.Header.Add("Content-Type", "application/json")
 
-	resp, err := spinhttp.Send(request)
+	resp, err := sendWithRetry(request, false)
 	if err != nil {
-		fmt.Println("Error sending POST request: " + err.Error())
+		LogError("Error sending POST request", Field("url", RedactUrl(url)), Field("error", err))
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		// Read the response body for logging and error details
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-		bodyStr := ""
-		if readErr == nil {
-			bodyStr = string(bodyBytes)
-		}
-		fmt.Println(fmt.Sprintf("The POST request returned status code %d with body: %s", resp.StatusCode, bodyStr))
-		return nil, fmt.Errorf("The POST request returned %d with body: %s", resp.StatusCode, bodyStr)
+		return nil, statusError(request, resp)
 	}
 	return resp, nil
 }
@@ -1162,11 +1213,11 @@ func SendGetWithHeader(url string, accessJwt string, additionalHeaders map[strin
 
 func SendGetWithHeaderLogConfigurable(url string, accessJwt string, additionalHeaders map[string]string, noLog bool) (*http.Response, error) {
 	if !noLog {
-		fmt.Println("Sending GET request to " + url)
+		LogInfo("Sending GET request", Field("url", RedactUrl(url)))
 	}
 	request, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		fmt.Println("Error creating GET request: " + err.Error())
+		LogError("Error creating GET request", Field("error", err))
 		return nil, err
 	}
 
@@ -1177,23 +1228,28 @@ func SendGetWithHeaderLogConfigurable(url string, accessJwt string, additionalHe
 		request.Header.Add(key, value)
 	}
 
-	resp, err := spinhttp.Send(request)
+	resp, err := sendWithRetry(request, true)
 
 	if err != nil {
-		fmt.Println("Error sending GET request: " + err.Error())
+		LogError("Error sending GET request", Field("url", RedactUrl(url)), Field("error", err))
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		// Read the response body for logging and error details
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-		bodyStr := ""
-		if readErr == nil {
-			bodyStr = string(bodyBytes)
-		}
-		fmt.Println(fmt.Sprintf("The GET request returned status code %d with body: %s", resp.StatusCode, bodyStr))
-		return nil, fmt.Errorf("The GET request returned %d with body: %s", resp.StatusCode, bodyStr)
+		return nil, statusError(request, resp)
 	}
 
 	return resp, nil
 }
+
+// statusError reads the response body for logging and error details
+func statusError(request *http.Request, resp *http.Response) error {
+	defer resp.Body.Close()
+	bodyBytes, readErr := io.ReadAll(resp.Body)
+	bodyStr := ""
+	if readErr == nil {
+		bodyStr = string(bodyBytes)
+	}
+	LogWarn("Request failed", Field("method", request.Method), Field("url", RedactUrl(request.URL.String())), Field("status", resp.StatusCode), Field("body", bodyStr))
+	return &HttpError{Method: request.Method, Url: RedactUrl(request.URL.String()), StatusCode: resp.StatusCode, Body: Redact(bodyStr), RetryAfter: retryAfter(resp)}
+}
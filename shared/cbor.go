@@ -0,0 +1,101 @@
+package shared
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// encodeDagCbor encodes the value as DAG-CBOR, the canonical CBOR that atproto signs and streams. Only the types used
+// for labels and event stream frames are supported
+func encodeDagCbor(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	err := writeCbor(&buffer, value)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func writeCbor(buffer *bytes.Buffer, value interface{}) error {
+	switch typed := value.(type) {
+	case bool:
+		if typed {
+			buffer.WriteByte(0xf5)
+		} else {
+			buffer.WriteByte(0xf4)
+		}
+	case int:
+		writeCborInt(buffer, int64(typed))
+	case int64:
+		writeCborInt(buffer, typed)
+	case string:
+		writeCborHeader(buffer, 3, uint64(len(typed)))
+		buffer.WriteString(typed)
+	case []byte:
+		writeCborHeader(buffer, 2, uint64(len(typed)))
+		buffer.Write(typed)
+	case []interface{}:
+		writeCborHeader(buffer, 4, uint64(len(typed)))
+		for _, item := range typed {
+			err := writeCbor(buffer, item)
+			if err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// DAG-CBOR sorts map keys by length first and then bytewise
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		writeCborHeader(buffer, 5, uint64(len(keys)))
+		for _, key := range keys {
+			writeCborHeader(buffer, 3, uint64(len(key)))
+			buffer.WriteString(key)
+			err := writeCbor(buffer, typed[key])
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("Unsupported type %T for DAG-CBOR", value)
+	}
+	return nil
+}
+
+func writeCborInt(buffer *bytes.Buffer, value int64) {
+	if value < 0 {
+		writeCborHeader(buffer, 1, uint64(-1-value))
+	} else {
+		writeCborHeader(buffer, 0, uint64(value))
+	}
+}
+
+// writeCborHeader writes the major type with the shortest possible encoding of the argument
+func writeCborHeader(buffer *bytes.Buffer, majorType byte, argument uint64) {
+	majorType <<= 5
+	switch {
+	case argument < 24:
+		buffer.WriteByte(majorType | byte(argument))
+	case argument <= 0xff:
+		buffer.WriteByte(majorType | 24)
+		buffer.WriteByte(byte(argument))
+	case argument <= 0xffff:
+		buffer.WriteByte(majorType | 25)
+		binary.Write(buffer, binary.BigEndian, uint16(argument))
+	case argument <= 0xffffffff:
+		buffer.WriteByte(majorType | 26)
+		binary.Write(buffer, binary.BigEndian, uint32(argument))
+	default:
+		buffer.WriteByte(majorType | 27)
+		binary.Write(buffer, binary.BigEndian, argument)
+	}
+}
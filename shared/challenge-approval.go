@@ -0,0 +1,144 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fermyon/spin/sdk/go/v2/kv"
+)
+
+const approvalKeyPrefix = "approval-"
+
+// ApprovalResponse is returned when a challenge verification waits for an admin
+type ApprovalResponse struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// the DID is part of the key, so a challenge of another account for the same ID doesn't replace the queued one
+func approvalKey(moduleKey string, bskyDid string, verificationId string) string {
+	return approvalKeyPrefix + moduleKey + "-" + bskyDid + "-" + verificationId
+}
+
+// QueueForApproval stores a challenge verification of a module that can't check the token at the source. An admin has
+// to confirm that the profile at the source belongs to the account before ApproveVerification runs the pipeline
+func QueueForApproval(naming Naming, verificationId string, bskyDid string, bskyHandle string, proofMode string, label string, levelLabels []string) (ApprovalResponse, error) {
+	pending := newPendingVerification(naming, verificationId, bskyDid, bskyHandle, proofMode, label, levelLabels)
+	pending.UpdatedAt = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	value, err := json.Marshal(pending)
+	if err != nil {
+		return ApprovalResponse{}, err
+	}
+
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return ApprovalResponse{}, err
+	}
+	defer store.Close()
+
+	key := approvalKey(naming.Key, bskyDid, verificationId)
+	LogInfo("Queueing challenge verification for approval", Field("key", key), Field("did", bskyDid))
+	err = store.Set(key, value)
+	if err != nil {
+		return ApprovalResponse{}, err
+	}
+	return ApprovalResponse{
+		Key:     key,
+		Message: "The challenge token was found. The verification is completed after an admin confirmed that the profile at the source belongs to your account.",
+	}, nil
+}
+
+// GetApprovals returns the challenge verifications that wait for an admin by their key
+func GetApprovals() (map[string]PendingVerification, error) {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	keys, err := store.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	approvals := map[string]PendingVerification{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, approvalKeyPrefix) {
+			continue
+		}
+		value, err := store.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		var pending PendingVerification
+		err = json.Unmarshal(value, &pending)
+		if err != nil {
+			LogError("Error decoding verification waiting for approval", Field("key", key), Field("error", err))
+			continue
+		}
+		approvals[key] = pending
+	}
+	return approvals, nil
+}
+
+// ApproveVerification runs the verification pipeline for a queued challenge verification and removes it from the queue
+func ApproveVerification(key string, accessJwt string, endpoint string) ([]ListOrStarterPackWithUrl, error) {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	if !strings.HasPrefix(key, approvalKeyPrefix) {
+		return nil, fmt.Errorf("Key " + key + " is not waiting for approval")
+	}
+	value, err := store.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading " + key + ": " + err.Error())
+	}
+	var pending PendingVerification
+	err = json.Unmarshal(value, &pending)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding " + key + ": " + err.Error())
+	}
+
+	// somebody else might have verified the ID with a link in the meantime
+	otherAccount, err := VerifiedForOtherAccount(pending.ModuleKey, pending.VerificationId, pending.Did, pending.Handle)
+	if err != nil {
+		return nil, err
+	}
+	if otherAccount {
+		return nil, fmt.Errorf("ID " + pending.VerificationId + " is already verified for another Bluesky account")
+	}
+
+	LogInfo("Approving challenge verification", Field("key", key), Field("did", pending.Did))
+	p := newVerificationPipeline(pending, accessJwt, endpoint)
+	result, err := p.run()
+	if err != nil {
+		return nil, err
+	}
+	return result, store.Delete(key)
+}
+
+// RejectVerification removes a queued challenge verification without running the pipeline
+func RejectVerification(key string) error {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if !strings.HasPrefix(key, approvalKeyPrefix) {
+		return fmt.Errorf("Key " + key + " is not waiting for approval")
+	}
+	exists, err := store.Exists(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Key " + key + " is not waiting for approval")
+	}
+	LogInfo("Rejecting challenge verification", Field("key", key))
+	return store.Delete(key)
+}
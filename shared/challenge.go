@@ -0,0 +1,160 @@
+package shared
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/fermyon/spin/sdk/go/v2/kv"
+)
+
+// Proof modes of a verification request
+const (
+	// the profile at the verification source links to the Bluesky account, the default
+	ProofModeLink = "link"
+	// the profile at the verification source exists and the Bluesky account carries a one-time token in its bio or a post.
+	// The token has to be on the profile at the source as well, modules that can't check that need an admin approval
+	ProofModeChallenge = "challenge"
+	// only checks that the profile at the verification source exists, used to re-validate challenge verifications
+	ProofModeExistence = "existence"
+)
+
+const challengeKeyPrefix = "challenge-"
+
+// ChallengeValidity is how long an issued challenge token can be used
+const ChallengeValidity = 48 * time.Hour
+
+// ChallengeEntry is stored in the default store while a challenge is open
+type ChallengeEntry struct {
+	Token          string `json:"token"`
+	VerificationId string `json:"verificationId"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+// ChallengeResponse is returned when a challenge token was issued
+type ChallengeResponse struct {
+	Challenge string `json:"challenge"`
+	Message   string `json:"message"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+func challengeKey(moduleKey string, bskyDid string) string {
+	return challengeKeyPrefix + moduleKey + "-" + bskyDid
+}
+
+func (c ChallengeEntry) expiresAt() time.Time {
+	createdAt, err := time.Parse("2006-01-02T15:04:05.000Z", c.CreatedAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return createdAt.Add(ChallengeValidity)
+}
+
+// GetOpenChallenge returns the challenge issued for the account and verificationId if it is not expired yet
+func GetOpenChallenge(moduleKey string, verificationId string, bskyDid string) (ChallengeEntry, bool, error) {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return ChallengeEntry{}, false, err
+	}
+	defer store.Close()
+
+	value, err := store.Get(challengeKey(moduleKey, bskyDid))
+	if err != nil {
+		if err.Error() == "no such key" {
+			return ChallengeEntry{}, false, nil
+		}
+		return ChallengeEntry{}, false, err
+	}
+
+	var challenge ChallengeEntry
+	err = json.Unmarshal(value, &challenge)
+	if err != nil {
+		return ChallengeEntry{}, false, nil
+	}
+	if challenge.VerificationId != verificationId || time.Now().UTC().After(challenge.expiresAt()) {
+		return ChallengeEntry{}, false, nil
+	}
+	return challenge, true, nil
+}
+
+// IssueChallenge creates a new one-time token for the account and stores it. With sourceProof, the token also has to be
+// put on the profile at the source
+func IssueChallenge(moduleKey string, verificationId string, bskyDid string, sourceProof bool) (ChallengeResponse, error) {
+	LogInfo("Issuing challenge", Field("did", bskyDid), Field("module", moduleKey))
+	random := make([]byte, 8)
+	_, err := rand.Read(random)
+	if err != nil {
+		return ChallengeResponse{}, err
+	}
+
+	challenge := ChallengeEntry{
+		Token:          "verifiedbsky-" + hex.EncodeToString(random),
+		VerificationId: verificationId,
+		CreatedAt:      time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+	}
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return ChallengeResponse{}, err
+	}
+
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return ChallengeResponse{}, err
+	}
+	defer store.Close()
+
+	err = store.Set(challengeKey(moduleKey, bskyDid), value)
+	if err != nil {
+		return ChallengeResponse{}, err
+	}
+	return challenge.response(sourceProof), nil
+}
+
+func (c ChallengeEntry) response(sourceProof bool) ChallengeResponse {
+	message := "Put " + c.Token + " in your Bluesky profile description or in a post and send the verification request again. An admin confirms the verification after that. You can remove the token after you are verified."
+	if sourceProof {
+		message = "Put " + c.Token + " in your Bluesky profile description or in a post and in your profile at the source, then send the verification request again. You can remove it after you are verified."
+	}
+	return ChallengeResponse{
+		Challenge: c.Token,
+		Message:   message,
+		ExpiresAt: c.expiresAt().Format("2006-01-02T15:04:05.000Z"),
+	}
+}
+
+// DeleteChallenge removes the challenge of the account after it was used
+func DeleteChallenge(moduleKey string, bskyDid string) error {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.Delete(challengeKey(moduleKey, bskyDid))
+}
+
+// AccountCarriesToken checks if the token is in the profile description or one of the recent posts of the account
+func AccountCarriesToken(bskyDid string, token string, accessJwt string, endpoint string) (bool, error) {
+	profile, err := GetProfile(bskyDid, accessJwt, endpoint)
+	if err != nil {
+		return false, err
+	}
+	if strings.Contains(profile.Description, token) {
+		LogInfo("Challenge token found in profile description", Field("did", bskyDid))
+		return true, nil
+	}
+
+	posts, err := GetRecentPostTexts(bskyDid, accessJwt, endpoint)
+	if err != nil {
+		return false, err
+	}
+	for _, post := range posts {
+		if strings.Contains(post, token) {
+			LogInfo("Challenge token found in a post", Field("did", bskyDid))
+			return true, nil
+		}
+	}
+	return false, nil
+}
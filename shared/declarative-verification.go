@@ -0,0 +1,467 @@
+package shared
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/antchfx/htmlquery"
+	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
+	"golang.org/x/net/html"
+)
+
+// SourceDefinition describes a verification source that can be checked without module-specific code.
+// Placeholders {{id}} and {{handle}} are replaced in url, body, headers and selectors
+type SourceDefinition struct {
+	Key                  string              `json:"key"`
+	Name                 string              `json:"name"`
+	NameShortened        string              `json:"nameShortened"`
+	Label                string              `json:"label"`
+	LabelLocales         []LabelLocale       `json:"labelLocales"`
+	LevelLabels          bool                `json:"levelLabels"`
+	AwardPeriodDays      int                 `json:"awardPeriodDays"`
+	ExplanationText      string              `json:"explanationText"`
+	Levels               map[string][]string `json:"levels"`
+	Level1TranslationMap map[string]string   `json:"level1Translations"`
+	Level2TranslationMap map[string]string   `json:"level2Translations"`
+	// html (selectors are XPath) or json (selectors are dot paths, [] iterates over an array)
+	Format string `json:"format"`
+	// GET (default) or POST
+	Method  string            `json:"method"`
+	Url     string            `json:"url"`
+	Body    string            `json:"body"`
+	Headers map[string]string `json:"headers"`
+	// query (default), path, json or none; applied to the id when it is put into url or body
+	IdEscaping string `json:"idEscaping"`
+	// json only: path to the records to look at and path inside a record that has to match the id
+	RecordSelector string `json:"recordSelector"`
+	IdSelector     string `json:"idSelector"`
+	// html: one of the selected nodes has to contain the Bluesky profile URL; json: one of the selected values has to be it
+	Selector string `json:"selector"`
+	// optional: values become first level entries, if levelPattern is set its first group is used
+	LevelSelector string `json:"levelSelector"`
+	LevelPattern  string `json:"levelPattern"`
+}
+
+//go:embed sources/*.json
+var sourceFiles embed.FS
+
+var sourceDefinitions = map[string]SourceDefinition{}
+
+func init() {
+	entries, err := sourceFiles.ReadDir("sources")
+	if err != nil {
+		LogError("Error reading source definitions", Field("error", err))
+		return
+	}
+	for _, entry := range entries {
+		content, err := sourceFiles.ReadFile("sources/" + entry.Name())
+		if err != nil {
+			LogError("Error reading source definition", Field("file", entry.Name()), Field("error", err))
+			continue
+		}
+		var def SourceDefinition
+		err = json.Unmarshal(content, &def)
+		if err != nil {
+			LogError("Error decoding source definition", Field("file", entry.Name()), Field("error", err))
+			continue
+		}
+		if def.Key == "" || def.Url == "" || def.Selector == "" {
+			LogWarn("Ignoring source definition because key, url or selector is missing", Field("file", entry.Name()))
+			continue
+		}
+		RegisterSource(def)
+	}
+}
+
+// RegisterSource adds a declarative verification source and registers it as module
+func RegisterSource(def SourceDefinition) {
+	sourceDefinitions[def.Key] = def
+	RegisterModule(def.ModuleSpecifics())
+}
+
+// GetSourceDefinition returns the declarative definition for a given moduleKey
+func GetSourceDefinition(moduleKey string) (SourceDefinition, bool) {
+	def, ok := sourceDefinitions[moduleKey]
+	return def, ok
+}
+
+// ModuleSpecifics turns the definition into a module with generic verification and naming functions
+func (def SourceDefinition) ModuleSpecifics() ModuleSpecifics {
+	levels := def.Levels
+	if levels == nil {
+		levels = make(map[string][]string)
+	}
+	level1TranslationMap := def.Level1TranslationMap
+	if level1TranslationMap == nil {
+		level1TranslationMap = make(map[string]string)
+	}
+	level2TranslationMap := def.Level2TranslationMap
+	if level2TranslationMap == nil {
+		level2TranslationMap = make(map[string]string)
+	}
+
+	m := ModuleSpecifics{
+		ModuleKey:            def.Key,
+		ModuleName:           def.Name,
+		ModuleNameShortened:  def.NameShortened,
+		ModuleLabel:          def.Label,
+		LabelLocales:         def.LabelLocales,
+		LevelLabelsEnabled:   def.LevelLabels,
+		AwardPeriod:          time.Duration(def.AwardPeriodDays) * 24 * time.Hour,
+		ExplanationText:      def.ExplanationText,
+		FirstAndSecondLevel:  levels,
+		Level1TranslationMap: level1TranslationMap,
+		Level2TranslationMap: level2TranslationMap,
+		VerificationFunc:     def.Verify,
+	}
+	// without a record that belongs to the ID, there is no way to tell if a profile exists
+	if def.Format == "json" && def.IdSelector != "" {
+		m.ExistenceFunc = def.exists
+		m.SourceTokenFunc = def.carriesToken
+		m.ChallengeSupported = true
+	}
+	if def.LevelSelector != "" {
+		m.NamingFunc = def.naming
+	} else {
+		m.NamingFunc = func(m ModuleSpecifics, _ string) (Naming, error) {
+			return SetupNamingStructure(m)
+		}
+	}
+	return m
+}
+
+// ServeSources handles the requests for all declarative sources. The module is identified by the
+// first path segment, so every source can keep its own /validate-<key> route
+func ServeSources() {
+	spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
+		moduleKey := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/validate-"), "/", 2)[0]
+		if _, ok := GetSourceDefinition(moduleKey); !ok {
+			http.Error(w, "No source definition found for "+moduleKey, http.StatusNotFound)
+			return
+		}
+		m, err := GetModuleSpecifics(moduleKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		m.Handle(w, r)
+	})
+}
+
+// Verify checks if the source links the Bluesky profile by handle or DID for the given verificationId
+func (def SourceDefinition) Verify(verificationId string, bskyHandle string, bskyDid string) (bool, error) {
+	LogInfo("Validating", Field("module", def.Key), Field("verificationId", verificationId))
+	profileUrl := "https://bsky.app/profile/" + bskyHandle
+
+	switch def.Format {
+	case "html":
+		doc, requestUrl, err := def.fetchHtml(verificationId, bskyHandle)
+		if err != nil {
+			return false, err
+		}
+		xpathQuery, err := def.xpathQuery(def.Selector, verificationId, bskyHandle)
+		if err != nil {
+			return false, err
+		}
+		LogDebug("XPath query", Field("query", xpathQuery))
+		nodes, err := htmlquery.QueryAll(doc, xpathQuery)
+		if err != nil {
+			LogError("Error performing XPath query", Field("module", def.Key), Field("error", err))
+			return false, fmt.Errorf("Could not find Bluesky URL " + profileUrl + " on the HTML profile at " + requestUrl + ": " + err.Error())
+		}
+		for _, node := range nodes {
+			// attribute nodes come with their value as text, for elements the attributes are checked as well
+			values := []string{htmlquery.InnerText(node)}
+			for _, attr := range node.Attr {
+				values = append(values, attr.Val)
+			}
+			for _, value := range values {
+				if ContainsBlueskyProfile(value, bskyHandle, bskyDid) {
+					return true, nil
+				}
+			}
+		}
+		LogInfo("Could not find Bluesky URL on the HTML profile", Field("profileUrl", profileUrl), Field("url", requestUrl))
+		return false, fmt.Errorf("Could not find Bluesky URL " + profileUrl + " on the HTML profile at " + requestUrl)
+
+	case "json":
+		records, err := def.fetchJsonRecords(verificationId, bskyHandle)
+		if err != nil {
+			return false, err
+		}
+		for _, record := range records {
+			for _, value := range selectJsonValues(record, def.replacePlaceholders(def.Selector, verificationId, bskyHandle)) {
+				if MatchesBlueskyProfile(fmt.Sprint(value), bskyHandle, bskyDid) {
+					LogInfo("Bluesky URL found", Field("profileUrl", profileUrl), Field("module", def.Key), Field("verificationId", verificationId))
+					return true, nil
+				}
+			}
+		}
+		LogInfo("Could not find Bluesky URL", Field("profileUrl", profileUrl), Field("module", def.Key), Field("verificationId", verificationId))
+		return false, fmt.Errorf("Link to social network with handle %s not found for %s %s", bskyHandle, def.Name, verificationId)
+	}
+
+	return false, fmt.Errorf("Unknown format " + def.Format + " in source definition " + def.Key)
+}
+
+func (def SourceDefinition) exists(verificationId string) (bool, error) {
+	LogInfo("Checking if the profile exists", Field("module", def.Key), Field("verificationId", verificationId))
+	_, err := def.fetchJsonRecords(verificationId, "")
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// carriesToken checks if one of the values of the record that belongs to the ID, e.g. the bio or a link, contains the token
+func (def SourceDefinition) carriesToken(verificationId string, token string) (bool, error) {
+	LogInfo("Checking if the profile carries the challenge token", Field("module", def.Key), Field("verificationId", verificationId))
+	records, err := def.fetchJsonRecords(verificationId, "")
+	if err != nil {
+		return false, err
+	}
+	for _, record := range records {
+		if jsonContains(record, token) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func jsonContains(value interface{}, token string) bool {
+	switch typed := value.(type) {
+	case string:
+		return strings.Contains(typed, token)
+	case []interface{}:
+		for _, item := range typed {
+			if jsonContains(item, token) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range typed {
+			if jsonContains(item, token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (def SourceDefinition) naming(m ModuleSpecifics, verificationId string) (Naming, error) {
+	LogInfo("Getting level", Field("module", def.Key), Field("verificationId", verificationId))
+	values := []string{}
+
+	switch def.Format {
+	case "html":
+		doc, requestUrl, err := def.fetchHtml(verificationId, "")
+		if err != nil {
+			return Naming{}, err
+		}
+		xpathQuery, err := def.xpathQuery(def.LevelSelector, verificationId, "")
+		if err != nil {
+			return Naming{}, err
+		}
+		nodes, err := htmlquery.QueryAll(doc, xpathQuery)
+		if err != nil {
+			LogError("Error performing XPath query", Field("module", def.Key), Field("error", err))
+			return Naming{}, fmt.Errorf("Could not find the level on the profile at " + requestUrl + ": " + err.Error())
+		}
+		for _, node := range nodes {
+			values = append(values, htmlquery.InnerText(node))
+		}
+
+	case "json":
+		records, err := def.fetchJsonRecords(verificationId, "")
+		if err != nil {
+			return Naming{}, err
+		}
+		for _, record := range records {
+			for _, value := range selectJsonValues(record, def.replacePlaceholders(def.LevelSelector, verificationId, "")) {
+				values = append(values, fmt.Sprint(value))
+			}
+		}
+
+	default:
+		return Naming{}, fmt.Errorf("Unknown format " + def.Format + " in source definition " + def.Key)
+	}
+
+	var levelPattern *regexp.Regexp
+	if def.LevelPattern != "" {
+		var err error
+		levelPattern, err = regexp.Compile(def.LevelPattern)
+		if err != nil {
+			return Naming{}, fmt.Errorf("Invalid level pattern in source definition " + def.Key + ": " + err.Error())
+		}
+	}
+
+	firstAndSecondLevel := map[string][]string{}
+	for _, value := range values {
+		level := strings.TrimSpace(value)
+		if levelPattern != nil {
+			match := levelPattern.FindStringSubmatch(level)
+			if len(match) < 2 {
+				continue
+			}
+			level = match[1]
+		}
+		if level != "" {
+			firstAndSecondLevel[level] = []string{}
+		}
+	}
+	if len(firstAndSecondLevel) == 0 {
+		LogWarn("Could not identify level", Field("module", def.Key), Field("verificationId", verificationId))
+		return Naming{}, fmt.Errorf("Could not identify level for %s with ID %s", def.Name, verificationId)
+	}
+
+	m.FirstAndSecondLevel = firstAndSecondLevel
+	return SetupNamingStructure(m)
+}
+
+func (def SourceDefinition) fetch(verificationId string, bskyHandle string) (*http.Response, string, error) {
+	escapedId := verificationId
+	switch def.IdEscaping {
+	case "", "query":
+		escapedId = url.QueryEscape(verificationId)
+	case "path":
+		escapedId = url.PathEscape(verificationId)
+	case "json":
+		encoded, err := json.Marshal(verificationId)
+		if err != nil {
+			return nil, "", err
+		}
+		escapedId = strings.Trim(string(encoded), "\"")
+	case "none":
+	default:
+		return nil, "", fmt.Errorf("Unknown id escaping " + def.IdEscaping + " in source definition " + def.Key)
+	}
+
+	requestUrl := def.replacePlaceholders(def.Url, escapedId, bskyHandle)
+	headers := map[string]string{}
+	for key, value := range def.Headers {
+		headers[key] = def.replacePlaceholders(value, escapedId, bskyHandle)
+	}
+
+	var resp *http.Response
+	var err error
+	if strings.EqualFold(def.Method, http.MethodPost) {
+		resp, err = SendPostWithHeaders(requestUrl, def.replacePlaceholders(def.Body, escapedId, bskyHandle), "", headers)
+	} else {
+		resp, err = SendGetWithHeader(requestUrl, "", headers)
+	}
+	if err != nil {
+		LogError("Error fetching the URL", Field("url", requestUrl), Field("error", err))
+		return nil, requestUrl, fmt.Errorf("Error fetching the " + def.Name + " profile at " + requestUrl + ": " + err.Error())
+	}
+	return resp, requestUrl, nil
+}
+
+func (def SourceDefinition) fetchHtml(verificationId string, bskyHandle string) (*html.Node, string, error) {
+	resp, requestUrl, err := def.fetch(verificationId, bskyHandle)
+	if err != nil {
+		return nil, requestUrl, err
+	}
+	defer resp.Body.Close()
+
+	doc, err := htmlquery.Parse(resp.Body)
+	if err != nil {
+		LogError("Error parsing HTML", Field("url", requestUrl), Field("error", err))
+		return nil, requestUrl, fmt.Errorf("Error parsing the HTML profile at " + requestUrl + ": " + err.Error())
+	}
+	return doc, requestUrl, nil
+}
+
+func (def SourceDefinition) fetchJsonRecords(verificationId string, bskyHandle string) ([]interface{}, error) {
+	resp, requestUrl, err := def.fetch(verificationId, bskyHandle)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading response body: " + err.Error())
+	}
+	var doc interface{}
+	err = json.Unmarshal(body, &doc)
+	if err != nil {
+		LogError("Error decoding JSON", Field("module", def.Key), Field("url", requestUrl), Field("error", err))
+		return nil, fmt.Errorf("Error decoding " + def.Name + " JSON from " + requestUrl + ": " + err.Error())
+	}
+
+	records := selectJsonValues(doc, def.replacePlaceholders(def.RecordSelector, verificationId, bskyHandle))
+	if def.IdSelector == "" {
+		return records, nil
+	}
+
+	matchingRecords := []interface{}{}
+	for _, record := range records {
+		for _, id := range selectJsonValues(record, def.IdSelector) {
+			if fmt.Sprint(id) == verificationId {
+				matchingRecords = append(matchingRecords, record)
+				break
+			}
+		}
+	}
+	if len(matchingRecords) == 0 {
+		LogInfo("Profile not found", Field("module", def.Key), Field("verificationId", verificationId))
+		return nil, fmt.Errorf(def.Name + " with ID " + verificationId + " not found")
+	}
+	return matchingRecords, nil
+}
+
+func (def SourceDefinition) xpathQuery(selector string, verificationId string, bskyHandle string) (string, error) {
+	// values end up in XPath string literals, so quotes would allow to change the query
+	if strings.ContainsAny(verificationId+bskyHandle, "'\"") {
+		return "", fmt.Errorf("Quotes are not allowed in the ID or handle for " + def.Name)
+	}
+	return def.replacePlaceholders(selector, verificationId, bskyHandle), nil
+}
+
+func (def SourceDefinition) replacePlaceholders(value string, verificationId string, bskyHandle string) string {
+	return strings.NewReplacer("{{id}}", verificationId, "{{handle}}", bskyHandle).Replace(value)
+}
+
+// selectJsonValues follows a dot separated path through decoded JSON. A segment ending with [] iterates
+// over an array, an empty path returns the value itself
+func selectJsonValues(value interface{}, path string) []interface{} {
+	values := []interface{}{value}
+	if path == "" {
+		return values
+	}
+	for _, segment := range strings.Split(path, ".") {
+		iterate := strings.HasSuffix(segment, "[]")
+		segment = strings.TrimSuffix(segment, "[]")
+		next := []interface{}{}
+		for _, v := range values {
+			if segment != "" {
+				object, ok := v.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				v, ok = object[segment]
+				if !ok {
+					continue
+				}
+			}
+			if iterate {
+				array, ok := v.([]interface{})
+				if !ok {
+					continue
+				}
+				next = append(next, array...)
+			} else {
+				next = append(next, v)
+			}
+		}
+		values = next
+	}
+	return values
+}
@@ -0,0 +1,61 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PdsEndpoint returns the endpoint of the #atproto_pds service. The id can be relative or prefixed with the DID
+func (d DidDoc) PdsEndpoint() (string, error) {
+	for _, service := range d.Service {
+		if service.ID == "#atproto_pds" || service.ID == d.ID+"#atproto_pds" {
+			if service.ServiceEndpoint == "" {
+				break
+			}
+			return strings.TrimRight(service.ServiceEndpoint, "/"), nil
+		}
+	}
+	return "", fmt.Errorf("No #atproto_pds service found in the DID document of " + d.ID)
+}
+
+// ResolveDidDocument gets the DID document of a did:plc from the configured PLC directory or of a did:web from its well-known location
+func ResolveDidDocument(did string) (DidDoc, error) {
+	var requestUrl string
+	switch {
+	case strings.HasPrefix(did, "did:plc:"):
+		requestUrl = PlcUrl() + "/" + did
+	case strings.HasPrefix(did, "did:web:"):
+		host, err := url.PathUnescape(strings.TrimPrefix(did, "did:web:"))
+		if err != nil {
+			return DidDoc{}, err
+		}
+		requestUrl = "https://" + host + "/.well-known/did.json"
+	default:
+		return DidDoc{}, fmt.Errorf("Unsupported DID method: " + did)
+	}
+
+	resp, err := SendGet(requestUrl, "")
+	if err != nil {
+		return DidDoc{}, err
+	}
+	defer resp.Body.Close()
+
+	var didDoc DidDoc
+	err = json.NewDecoder(resp.Body).Decode(&didDoc)
+	if err != nil {
+		return DidDoc{}, err
+	}
+	return didDoc, nil
+}
+
+// ResolvePdsEndpoint returns the endpoint of the PDS that hosts the repo of the DID
+func ResolvePdsEndpoint(did string) (string, error) {
+	LogDebug("Resolving PDS", Field("did", did))
+	didDoc, err := ResolveDidDocument(did)
+	if err != nil {
+		return "", err
+	}
+	return didDoc.PdsEndpoint()
+}
@@ -0,0 +1,59 @@
+package shared
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/fermyon/spin/sdk/go/v2/variables"
+)
+
+// Defaults of the base URLs that can be overwritten with Spin variables, e.g. for a local test PDS or a staging account
+const (
+	DefaultPlcUrl     = "https://plc.directory"
+	DefaultChatUrl    = "https://api.bsky.chat"
+	DefaultAppViewUrl = "https://api.bsky.app"
+	DefaultWebUrl     = "https://bsky.app"
+)
+
+// PdsUrl returns the configured PDS of the service account. If it is empty, the PDS is resolved from the DID document
+func PdsUrl() string {
+	return endpointVariable("bsky_pds_url", "")
+}
+
+// PlcUrl returns the PLC directory used to resolve did:plc identifiers
+func PlcUrl() string {
+	return endpointVariable("bsky_plc_url", DefaultPlcUrl)
+}
+
+// ChatUrl returns the service that handles the chat.bsky.convo endpoints
+func ChatUrl() string {
+	return endpointVariable("bsky_chat_url", DefaultChatUrl)
+}
+
+// AppViewUrl returns the AppView that answers the app.bsky.* queries
+func AppViewUrl() string {
+	return endpointVariable("bsky_appview_url", DefaultAppViewUrl)
+}
+
+// AppViewProxy returns the atproto-proxy header value that makes the PDS forward a query to the configured AppView
+func AppViewProxy() string {
+	host := AppViewUrl()
+	if parsed, err := url.Parse(host); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+	return "did:web:" + host + "#bsky_appview"
+}
+
+// WebUrl returns the web client used to build links to profiles, lists and starter packs
+func WebUrl() string {
+	return endpointVariable("bsky_web_url", DefaultWebUrl)
+}
+
+// variables that are not declared for a component fall back to the default as well
+func endpointVariable(name string, defaultValue string) string {
+	value, err := variables.Get(name)
+	if err != nil || strings.TrimSpace(value) == "" {
+		return defaultValue
+	}
+	return strings.TrimRight(strings.TrimSpace(value), "/")
+}
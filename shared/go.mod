@@ -7,12 +7,12 @@ replace github.com/s => ../shared
 require (
 	github.com/antchfx/htmlquery v1.3.4
 	github.com/fermyon/spin/sdk/go/v2 v2.2.0
+	golang.org/x/net v0.33.0
 )
 
 require (
 	github.com/antchfx/xpath v1.3.3 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/julienschmidt/httprouter v1.3.0 // indirect
-	golang.org/x/net v0.33.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 )
@@ -0,0 +1,224 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
+)
+
+// Retry behaviour of the shared HTTP layer
+const (
+	MaxAttempts = 4
+	// the first backoff, doubled with every retry and randomized with jitter
+	BaseBackoff = 500 * time.Millisecond
+	// if a service asks to wait longer than this, the request fails instead of blocking the component
+	MaxRetryWait = 30 * time.Second
+	// part of the rate limit of the PDS that heavy operations like starter pack rebuilds leave for verifications
+	HeavyOperationReserve = 500
+)
+
+// HttpError is returned for responses with a non-200 status code
+type HttpError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Body       string
+	// how long the service asked to wait before the next request, if it did
+	RetryAfter time.Duration
+}
+
+func (e *HttpError) Error() string {
+	return fmt.Sprintf("The %s request returned %d with body: %s", e.Method, e.StatusCode, e.Body)
+}
+
+// BudgetExhaustedError is returned without sending the request if the budget of a host is used up
+type BudgetExhaustedError struct {
+	Host       string
+	RetryAfter time.Duration
+}
+
+func (e *BudgetExhaustedError) Error() string {
+	return "The request budget for " + e.Host + " is exhausted, retry after " + e.RetryAfter.Round(time.Second).String()
+}
+
+// IsClientError checks if the request failed with a 4xx status code, which doesn't get better with a retry
+func IsClientError(err error) bool {
+	statusCode := errorStatusCode(err)
+	return statusCode >= 400 && statusCode < 500
+}
+
+// IsServerError checks if the request failed with a 5xx status code
+func IsServerError(err error) bool {
+	return errorStatusCode(err) >= 500
+}
+
+// IsRateLimited checks if the request was rejected by a rate limit or the budget of the host
+func IsRateLimited(err error) bool {
+	var budgetError *BudgetExhaustedError
+	return errors.As(err, &budgetError) || errorStatusCode(err) == http.StatusTooManyRequests
+}
+
+func errorStatusCode(err error) int {
+	var httpError *HttpError
+	if errors.As(err, &httpError) {
+		return httpError.StatusCode
+	}
+	var xrpcError *XrpcError
+	if errors.As(err, &xrpcError) {
+		return xrpcError.StatusCode
+	}
+	return 0
+}
+
+type hostBudget struct {
+	maxRequests int
+	reserve     int
+	used        int
+	// last values of the ratelimit-* headers, remaining is -1 until a response had them
+	remaining int
+	reset     time.Time
+}
+
+// budgets live as long as the component instance, which is one incoming request in Spin
+var hostBudgets = map[string]*hostBudget{}
+
+func budgetFor(host string) *hostBudget {
+	budget, ok := hostBudgets[host]
+	if !ok {
+		budget = &hostBudget{remaining: -1}
+		hostBudgets[host] = budget
+	}
+	return budget
+}
+
+// SetHostBudget limits the requests to a host for the rest of the incoming request. maxRequests 0 means no limit,
+// reserve is the part of the rate limit reported by the host that is left for other operations
+func SetHostBudget(host string, maxRequests int, reserve int) {
+	budget := budgetFor(host)
+	budget.maxRequests = maxRequests
+	budget.reserve = reserve
+	budget.used = 0
+}
+
+// ClearHostBudget removes the limits of a host
+func ClearHostBudget(host string) {
+	budget := budgetFor(host)
+	budget.maxRequests = 0
+	budget.reserve = 0
+}
+
+// ReserveForHeavyOperation keeps part of the rate limit of the endpoint for other operations and returns a func that removes the limit again
+func ReserveForHeavyOperation(endpoint string) func() {
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return func() {}
+	}
+	SetHostBudget(endpointUrl.Host, 0, HeavyOperationReserve)
+	return func() { ClearHostBudget(endpointUrl.Host) }
+}
+
+// waitForBudget blocks until the host accepts another request or returns an error if that takes too long
+func (b *hostBudget) waitForBudget(host string) error {
+	if b.maxRequests > 0 && b.used >= b.maxRequests {
+		return &BudgetExhaustedError{Host: host}
+	}
+	if b.remaining >= 0 && b.remaining <= b.reserve {
+		wait := time.Until(b.reset)
+		if wait > MaxRetryWait {
+			return &BudgetExhaustedError{Host: host, RetryAfter: wait}
+		}
+		if wait > 0 {
+			LogWarn("Rate limit almost reached, waiting", Field("host", host), Field("wait", wait.Round(time.Millisecond).String()))
+			time.Sleep(wait)
+		}
+		b.remaining = -1
+	}
+	return nil
+}
+
+func (b *hostBudget) update(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("ratelimit-remaining"))
+	if err != nil {
+		return
+	}
+	b.remaining = remaining
+	if reset, err := strconv.ParseInt(resp.Header.Get("ratelimit-reset"), 10, 64); err == nil {
+		b.reset = time.Unix(reset, 0)
+	}
+}
+
+// retryAfter reads how long to wait from the Retry-After header or, for an exhausted rate limit, the ratelimit-reset header
+func retryAfter(resp *http.Response) time.Duration {
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return time.Until(date)
+		}
+	}
+	if resp.Header.Get("ratelimit-remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("ratelimit-reset"), 10, 64); err == nil {
+			return time.Until(time.Unix(reset, 0))
+		}
+	}
+	return 0
+}
+
+func backoff(attempt int) time.Duration {
+	wait := BaseBackoff << attempt
+	return wait/2 + time.Duration(rand.Int63n(int64(wait)))
+}
+
+// sendWithRetry sends the request and retries it on transport errors and 5xx if it is idempotent and on 429 in any case,
+// as a rate limited request was not processed. The response of the last attempt is returned, whatever its status code
+func sendWithRetry(request *http.Request, idempotent bool) (*http.Response, error) {
+	budget := budgetFor(request.URL.Host)
+	for attempt := 0; ; attempt++ {
+		err := budget.waitForBudget(request.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+		if attempt > 0 && request.GetBody != nil {
+			request.Body, err = request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		budget.used++
+		resp, err := spinhttp.Send(request)
+		lastAttempt := attempt == MaxAttempts-1
+		if err != nil {
+			if !idempotent || lastAttempt {
+				return nil, err
+			}
+			wait := backoff(attempt)
+			LogWarn("Error sending request, retrying", Field("method", request.Method), Field("url", RedactUrl(request.URL.String())), Field("wait", wait.Round(time.Millisecond).String()), Field("error", err))
+			time.Sleep(wait)
+			continue
+		}
+		budget.update(resp)
+
+		retryable := resp.StatusCode == http.StatusTooManyRequests || (idempotent && resp.StatusCode >= 500)
+		if !retryable || lastAttempt {
+			return resp, nil
+		}
+		wait := retryAfter(resp)
+		if wait > MaxRetryWait {
+			return resp, nil
+		}
+		if wait <= 0 {
+			wait = backoff(attempt)
+		}
+		resp.Body.Close()
+		LogWarn("Request failed, retrying", Field("method", request.Method), Field("url", RedactUrl(request.URL.String())), Field("status", resp.StatusCode), Field("wait", wait.Round(time.Millisecond).String()))
+		time.Sleep(wait)
+	}
+}
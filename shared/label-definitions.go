@@ -0,0 +1,182 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/fermyon/spin/sdk/go/v2/variables"
+)
+
+// LabelLocale is the name and description of a label in one language, as shown by the Bluesky clients
+type LabelLocale struct {
+	Lang        string `json:"lang"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// LabelValueDefinition is an app.bsky.labeler.defs#labelValueDefinition
+type LabelValueDefinition struct {
+	Identifier     string        `json:"identifier"`
+	Severity       string        `json:"severity"`
+	Blurs          string        `json:"blurs"`
+	DefaultSetting string        `json:"defaultSetting"`
+	AdultOnly      bool          `json:"adultOnly"`
+	Locales        []LabelLocale `json:"locales"`
+}
+
+type getRecordResponse struct {
+	URI   string                 `json:"uri"`
+	Cid   string                 `json:"cid"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// label values may only contain lowercase letters and hyphens, otherwise the clients ignore them
+var labelValuePattern = regexp.MustCompile(`^[a-z-]{1,100}$`)
+
+// DefaultLabelLocales describes the label of a module in English and German, generated from the module name
+func (m ModuleSpecifics) DefaultLabelLocales() []LabelLocale {
+	return []LabelLocale{
+		{
+			Lang:        "en",
+			Name:        m.ModuleNameShortened,
+			Description: "This account was verified as one of the " + m.ModuleName + ". The profile at the source links to this Bluesky account and is checked regularly.",
+		},
+		{
+			Lang:        "de",
+			Name:        m.ModuleNameShortened,
+			Description: "Dieser Account wurde als Teil der " + m.ModuleName + " verifiziert. Das Profil bei der Quelle verlinkt auf diesen Bluesky-Account und wird regelmäßig überprüft.",
+		},
+	}
+}
+
+// LevelLabelLocales describes the label of a first level in English and German
+func (m ModuleSpecifics) LevelLabelLocales(firstLevel string) []LabelLocale {
+	name := m.ModuleNameShortened + ": " + firstLevel
+	// the clients cut names after 64 characters
+	if translated, ok := m.Level1TranslationMap[firstLevel]; ok && len(name) > 64 {
+		name = m.ModuleNameShortened + ": " + translated
+	}
+	return []LabelLocale{
+		{
+			Lang:        "en",
+			Name:        name,
+			Description: "This account was verified as one of the " + m.ModuleName + " in " + firstLevel + ". The profile at the source links to this Bluesky account and is checked regularly.",
+		},
+		{
+			Lang:        "de",
+			Name:        name,
+			Description: "Dieser Account wurde als Teil der " + m.ModuleName + " in " + firstLevel + " verifiziert. Das Profil bei der Quelle verlinkt auf diesen Bluesky-Account und wird regelmäßig überprüft.",
+		},
+	}
+}
+
+// GenerateLabelValueDefinitions returns one informational label definition per label of the registered modules and
+// their first levels, sorted by label. Modules can replace the generated descriptions of the module label with their
+// own LabelLocales
+func GenerateLabelValueDefinitions() []LabelValueDefinition {
+	definitions := map[string]LabelValueDefinition{}
+	addDefinition := func(m ModuleSpecifics, label string, locales []LabelLocale) {
+		if !labelValuePattern.MatchString(label) {
+			LogWarn("Ignoring label because it is not a valid label value", Field("label", label), Field("module", m.ModuleKey))
+			return
+		}
+		if _, ok := definitions[label]; ok {
+			return
+		}
+		definitions[label] = LabelValueDefinition{
+			Identifier:     label,
+			Severity:       "inform",
+			Blurs:          "none",
+			DefaultSetting: "warn",
+			AdultOnly:      false,
+			Locales:        locales,
+		}
+	}
+
+	for _, m := range GetAllModuleSpecifics() {
+		locales := m.LabelLocales
+		if len(locales) == 0 {
+			locales = m.DefaultLabelLocales()
+		}
+		addDefinition(m, m.ModuleLabel, locales)
+
+		if m.LevelLabelsEnabled {
+			for first := range m.FirstAndSecondLevel {
+				addDefinition(m, m.LevelLabel(first), m.LevelLabelLocales(first))
+			}
+		}
+	}
+
+	result := make([]LabelValueDefinition, 0, len(definitions))
+	for _, definition := range definitions {
+		result = append(result, definition)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Identifier < result[j].Identifier
+	})
+	return result
+}
+
+// PublishLabelDefinitions writes the generated label definitions to the app.bsky.labeler.service record of the labeler
+// account. Other fields of an existing record are kept. The session is the one of the service account, so this only
+// works if the service account is the labeler as well
+func PublishLabelDefinitions(accessJwt string, endpoint string) ([]LabelValueDefinition, error) {
+	bskyDid, err := variables.Get("bsky_did")
+	if err != nil {
+		return nil, err
+	}
+	bskyLabelerDid, err := variables.Get("bsky_labeler_did")
+	if err != nil {
+		return nil, err
+	}
+	if bskyDid != bskyLabelerDid {
+		return nil, fmt.Errorf("The label definitions have to be published to the repo of the labeler " + bskyLabelerDid + ", but the service account " + bskyDid + " can only write to its own repo")
+	}
+	client := NewXrpcClient(endpoint, accessJwt)
+
+	record := map[string]interface{}{}
+	var existing getRecordResponse
+	err = client.Query("com.atproto.repo.getRecord", url.Values{"repo": {bskyLabelerDid}, "collection": {"app.bsky.labeler.service"}, "rkey": {"self"}}, &existing)
+	if err != nil {
+		var xrpcError *XrpcError
+		if !errors.As(err, &xrpcError) || xrpcError.ErrorName != "RecordNotFound" {
+			return nil, err
+		}
+		LogInfo("No labeler service record yet, creating it")
+	} else if existing.Value != nil {
+		record = existing.Value
+	}
+
+	definitions := GenerateLabelValueDefinitions()
+	labelValues := make([]string, 0, len(definitions))
+	for _, definition := range definitions {
+		labelValues = append(labelValues, definition.Identifier)
+	}
+
+	record["$type"] = "app.bsky.labeler.service"
+	record["policies"] = map[string]interface{}{
+		"labelValues":           labelValues,
+		"labelValueDefinitions": definitions,
+	}
+	if _, ok := record["createdAt"]; !ok {
+		record["createdAt"] = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	}
+
+	request := PutRecordRequest{
+		Repo:       bskyLabelerDid,
+		Collection: "app.bsky.labeler.service",
+		Rkey:       "self",
+		Record:     record,
+	}
+	err = client.Procedure("com.atproto.repo.putRecord", request, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	LogInfo("Published label definitions", Field("count", len(definitions)))
+	return definitions, nil
+}
@@ -0,0 +1,70 @@
+package shared
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fermyon/spin/sdk/go/v2/kv"
+)
+
+// LabelRenewalInterval is how much later a label has to expire after a re-validation before it is emitted again, so
+// the weekly validation doesn't emit every label every week
+const LabelRenewalInterval = 30 * 24 * time.Hour
+
+// LabelExpiry returns when labels set now expire, a zero time if the module has no award period
+func (m ModuleSpecifics) LabelExpiry() time.Time {
+	if m.AwardPeriod <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(m.AwardPeriod)
+}
+
+// labelIsCurrent checks if an existing label with the expiry currentExp covers a label that should expire at exp
+func labelIsCurrent(currentExp string, exp time.Time) bool {
+	if currentExp == "" {
+		// a label without expiry only needs one if the module has an award period now
+		return exp.IsZero()
+	}
+	current, err := time.Parse(time.RFC3339, currentExp)
+	if err != nil || current.Before(time.Now()) {
+		return false
+	}
+	return exp.IsZero() || !current.Before(exp.Add(-LabelRenewalInterval))
+}
+
+// RenewLabels extends the expiry of the module label and the level labels of a stored verification after it was
+// validated successfully. Modules without award period are skipped
+func RenewLabels(key string, accessJwt string, endpoint string) error {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return err
+	}
+	value, err := store.Get(key)
+	store.Close()
+	if err != nil {
+		return err
+	}
+
+	entry := ParseStoreEntryWithKey(key, value)
+	m, err := GetModuleSpecifics(entry.ModuleKey)
+	if err != nil {
+		return err
+	}
+	exp := m.LabelExpiry()
+	if exp.IsZero() {
+		return nil
+	}
+	target := entry.Did
+	if target == "" {
+		target = entry.Handle
+	}
+
+	LogInfo("Renewing labels", Field("target", target), Field("exp", exp.UTC().Format("2006-01-02")))
+	for _, label := range append([]string{m.ModuleLabel}, entry.LevelLabels...) {
+		err = SetLabelWithExpiry(label, target, exp, accessJwt, endpoint)
+		if err != nil {
+			return fmt.Errorf("Error renewing label " + label + ": " + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fermyon/spin/sdk/go/v2/kv"
+	"github.com/fermyon/spin/sdk/go/v2/variables"
+)
+
+// LabelDrift is a label that a subject should have but doesn't or that it has without a verification in the k/v store
+type LabelDrift struct {
+	Did   string `json:"did"`
+	Label string `json:"label"`
+	// the verification the label belongs to, empty for orphaned labels
+	Key   string `json:"key,omitempty"`
+	Fixed bool   `json:"fixed"`
+	Error string `json:"error,omitempty"`
+}
+
+// LabelReconciliationResult reports the differences between the labeler and the k/v store
+type LabelReconciliationResult struct {
+	Apply    bool         `json:"apply"`
+	Subjects int          `json:"subjects"`
+	Missing  []LabelDrift `json:"missing"`
+	Orphaned []LabelDrift `json:"orphaned"`
+	// verifications that can't be compared, e.g. legacy entries whose handle can't be resolved or of unknown modules.
+	// Their labels would look orphaned, so nothing is applied while there are any
+	Skipped []string `json:"skipped"`
+	// subjects of the labeler whose labels can't be read, e.g. deleted or taken down accounts. They are left as they are
+	Unreadable []string `json:"unreadable"`
+}
+
+type ModerationStatusesResponse struct {
+	Cursor          string `json:"cursor"`
+	SubjectStatuses []struct {
+		Subject struct {
+			Did string `json:"did"`
+		} `json:"subject"`
+	} `json:"subjectStatuses"`
+}
+
+type expectedLabel struct {
+	key string
+	exp time.Time
+}
+
+// ReconcileLabels compares the labels of all subjects of the labeler with the verifications in the k/v store. Only label
+// values of the registered modules are compared. With apply, missing labels are set and orphaned labels are removed,
+// otherwise the differences are only reported. Apply is refused while verifications are skipped
+func ReconcileLabels(apply bool, accessJwt string, endpoint string) (LabelReconciliationResult, error) {
+	result := LabelReconciliationResult{Apply: apply, Missing: []LabelDrift{}, Orphaned: []LabelDrift{}, Skipped: []string{}, Unreadable: []string{}}
+
+	expected, knownLabels, skipped, err := expectedLabels(accessJwt, endpoint)
+	if err != nil {
+		return result, err
+	}
+	result.Skipped = skipped
+	if apply && len(skipped) > 0 {
+		return result, fmt.Errorf("%d verifications can't be compared, migrate them to DIDs or remove them before applying: %s", len(skipped), strings.Join(skipped, ", "))
+	}
+
+	var actual map[string]map[string]bool
+	unreadable := map[string]string{}
+	if LabelerMode() == LabelerModeBuiltin {
+		actual, err = builtinLabelState()
+	} else {
+		actual, unreadable, err = ozoneLabelState(accessJwt, endpoint)
+	}
+	if err != nil {
+		return result, err
+	}
+	for did, reason := range unreadable {
+		result.Unreadable = append(result.Unreadable, did+": "+reason)
+	}
+	sort.Strings(result.Unreadable)
+
+	subjects := map[string]bool{}
+	for did := range expected {
+		subjects[did] = true
+	}
+	for did := range actual {
+		subjects[did] = true
+	}
+	result.Subjects = len(subjects)
+
+	for did, labels := range expected {
+		if _, ok := unreadable[did]; ok {
+			continue
+		}
+		for label, expectation := range labels {
+			if actual[did][label] {
+				continue
+			}
+			drift := LabelDrift{Did: did, Label: label, Key: expectation.key}
+			if apply {
+				err = SetLabelWithExpiry(label, did, expectation.exp, accessJwt, endpoint)
+				drift.Fixed = err == nil
+				if err != nil {
+					drift.Error = err.Error()
+				}
+			}
+			result.Missing = append(result.Missing, drift)
+		}
+	}
+	for did, labels := range actual {
+		for label := range labels {
+			if !knownLabels[label] {
+				continue
+			}
+			if _, ok := expected[did][label]; ok {
+				continue
+			}
+			drift := LabelDrift{Did: did, Label: label}
+			if apply {
+				err = RemoveLabel(label, did, accessJwt, endpoint)
+				drift.Fixed = err == nil
+				if err != nil {
+					drift.Error = err.Error()
+				}
+			}
+			result.Orphaned = append(result.Orphaned, drift)
+		}
+	}
+
+	sortDrift(result.Missing)
+	sortDrift(result.Orphaned)
+	LogInfo("Compared labels", Field("subjects", result.Subjects), Field("missing", len(result.Missing)), Field("orphaned", len(result.Orphaned)), Field("unreadable", len(result.Unreadable)))
+	return result, nil
+}
+
+// expectedLabels returns the labels every verified DID should have and all label values the modules can set. The DID
+// of legacy entries is resolved from their handle
+func expectedLabels(accessJwt string, endpoint string) (map[string]map[string]expectedLabel, map[string]bool, []string, error) {
+	expected := map[string]map[string]expectedLabel{}
+	knownLabels := map[string]bool{}
+	skipped := []string{}
+
+	for _, definition := range GenerateLabelValueDefinitions() {
+		knownLabels[definition.Identifier] = true
+	}
+
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer store.Close()
+	keys, err := store.GetKeys()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, key := range keys {
+		if !IsVerificationKey(key) {
+			continue
+		}
+		value, err := store.Get(key)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		entry := ParseStoreEntryWithKey(key, value)
+		m, err := GetModuleSpecifics(entry.ModuleKey)
+		if err != nil {
+			skipped = append(skipped, key)
+			continue
+		}
+		if entry.Did == "" {
+			profile, err := GetProfile(entry.Handle, accessJwt, endpoint)
+			if err != nil || profile.DID == "" {
+				LogWarn("Could not resolve DID for handle", Field("handle", entry.Handle), Field("key", key))
+				skipped = append(skipped, key)
+				continue
+			}
+			entry.Did = profile.DID
+		}
+
+		if expected[entry.Did] == nil {
+			expected[entry.Did] = map[string]expectedLabel{}
+		}
+		for _, label := range append([]string{m.ModuleLabel}, entry.LevelLabels...) {
+			knownLabels[label] = true
+			expected[entry.Did][label] = expectedLabel{key: key, exp: m.LabelExpiry()}
+		}
+	}
+	sort.Strings(skipped)
+	return expected, knownLabels, skipped, nil
+}
+
+// builtinLabelState returns the labels of all subjects of the built-in labeler that haven't expired
+func builtinLabelState() (map[string]map[string]bool, error) {
+	store, err := kv.OpenStore(labelStore)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	events, err := loadLabelEvents(store, []string{"*"})
+	if err != nil {
+		return nil, err
+	}
+
+	actual := map[string]map[string]bool{}
+	for _, event := range events {
+		if actual[event.Label.Uri] == nil {
+			actual[event.Label.Uri] = map[string]bool{}
+		}
+		if !event.Label.Neg && labelIsCurrent(event.Label.Exp, time.Time{}) {
+			actual[event.Label.Uri][event.Label.Val] = true
+		}
+	}
+	return actual, nil
+}
+
+// ozoneLabelState enumerates the subjects of the Ozone instance and returns the labels they carry from our labeler. A
+// subject whose repo can't be read, e.g. because the account was deleted, is returned with the reason instead. Only
+// authentication and transport errors abort
+func ozoneLabelState(accessJwt string, endpoint string) (map[string]map[string]bool, map[string]string, error) {
+	bskyDid, err := variables.Get("bsky_did")
+	if err != nil {
+		return nil, nil, err
+	}
+	bskyLabelerDid, err := variables.Get("bsky_labeler_did")
+	if err != nil {
+		return nil, nil, err
+	}
+	client := NewXrpcClient(endpoint, accessJwt).WithHeaders(map[string]string{"atproto-accept-labelers": bskyLabelerDid + ";redact", "atproto-proxy": bskyDid + "#atproto_labeler"})
+
+	subjects := []string{}
+	params := url.Values{"limit": {"100"}}
+	for {
+		var response ModerationStatusesResponse
+		err = client.Query("tools.ozone.moderation.queryStatuses", params, &response)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, status := range response.SubjectStatuses {
+			// statuses of records and blobs don't have a DID
+			if status.Subject.Did != "" {
+				subjects = append(subjects, status.Subject.Did)
+			}
+		}
+		if response.Cursor == "" {
+			break
+		}
+		params.Set("cursor", response.Cursor)
+	}
+
+	actual := map[string]map[string]bool{}
+	unreadable := map[string]string{}
+	for _, did := range subjects {
+		var response ModerationRepoResponse
+		err = client.Query("tools.ozone.moderation.getRepo", url.Values{"did": {did}}, &response)
+		if err != nil {
+			var xrpcError *XrpcError
+			if !errors.As(err, &xrpcError) || failsForAllSubjects(xrpcError) {
+				return nil, nil, err
+			}
+			LogWarn("Skipping labeler subject whose repo can't be read", Field("did", did), Field("error", err))
+			unreadable[did] = err.Error()
+			continue
+		}
+		actual[did] = map[string]bool{}
+		for _, label := range response.Labels {
+			if label.Src == bskyLabelerDid && labelIsCurrent(label.Exp, time.Time{}) {
+				actual[did][label.Val] = true
+			}
+		}
+	}
+	return actual, unreadable, nil
+}
+
+// failsForAllSubjects checks if a request failed because of the session or the service, not because of the subject
+func failsForAllSubjects(xrpcError *XrpcError) bool {
+	switch xrpcError.ErrorName {
+	case "AuthRequired", "ExpiredToken", "InvalidToken":
+		return true
+	}
+	return xrpcError.StatusCode == http.StatusUnauthorized || xrpcError.StatusCode == http.StatusForbidden || xrpcError.StatusCode >= http.StatusInternalServerError
+}
+
+func sortDrift(drift []LabelDrift) {
+	sort.Slice(drift, func(i, j int) bool {
+		if drift[i].Did != drift[j].Did {
+			return drift[i].Did < drift[j].Did
+		}
+		return drift[i].Label < drift[j].Label
+	})
+}
@@ -0,0 +1,463 @@
+package shared
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fermyon/spin/sdk/go/v2/kv"
+	"github.com/fermyon/spin/sdk/go/v2/variables"
+)
+
+// Targets for SetLabel and RemoveLabel, configured with the Spin variable labeler_mode
+const (
+	LabelerModeOzone   = "ozone"
+	LabelerModeBuiltin = "builtin"
+)
+
+// labelStore keeps the current label of every subject and value of the built-in labeler under "<DID> <value>", so
+// concurrent labels of different values don't overwrite each other and listing the keys only returns labels
+const labelStore = "labels"
+
+// LabelSig is encoded as {"$bytes": "<base64>"} like all bytes in atproto JSON
+type LabelSig []byte
+
+func (s LabelSig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"$bytes": base64.RawStdEncoding.EncodeToString(s)})
+}
+
+func (s *LabelSig) UnmarshalJSON(data []byte) error {
+	var encoded map[string]string
+	err := json.Unmarshal(data, &encoded)
+	if err != nil {
+		return err
+	}
+	decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded["$bytes"], "="))
+	if err != nil {
+		return err
+	}
+	*s = decoded
+	return nil
+}
+
+// SignedLabel is a com.atproto.label.defs#label as served by the built-in labeler
+type SignedLabel struct {
+	Ver int      `json:"ver"`
+	Src string   `json:"src"`
+	Uri string   `json:"uri"`
+	Cid string   `json:"cid,omitempty"`
+	Val string   `json:"val"`
+	Neg bool     `json:"neg,omitempty"`
+	Cts string   `json:"cts"`
+	Exp string   `json:"exp,omitempty"`
+	Sig LabelSig `json:"sig,omitempty"`
+}
+
+// LabelEvent is the latest label of a subject and value. The ID starts with the time it was emitted, so ordering by ID
+// orders by time and it works as cursor for queryLabels
+type LabelEvent struct {
+	Id    string      `json:"id"`
+	Label SignedLabel `json:"label"`
+}
+
+// cborValue returns the label as it is signed, the optional fields are only part of it if they are set
+func (l SignedLabel) cborValue() map[string]interface{} {
+	value := map[string]interface{}{
+		"ver": l.Ver,
+		"src": l.Src,
+		"uri": l.Uri,
+		"val": l.Val,
+		"cts": l.Cts,
+	}
+	if l.Cid != "" {
+		value["cid"] = l.Cid
+	}
+	if l.Neg {
+		value["neg"] = true
+	}
+	if l.Exp != "" {
+		value["exp"] = l.Exp
+	}
+	return value
+}
+
+// LabelerMode returns if labels are emitted through Ozone or the built-in labeler
+func LabelerMode() string {
+	mode, err := variables.Get("labeler_mode")
+	if err != nil || strings.TrimSpace(mode) == "" {
+		return LabelerModeOzone
+	}
+	return strings.ToLower(strings.TrimSpace(mode))
+}
+
+// labelerSigningKey reads the P-256 private key of the labeler, hex encoded in the Spin variable labeler_signing_key
+func labelerSigningKey() (*ecdsa.PrivateKey, error) {
+	encodedKey, err := variables.Get("labeler_signing_key")
+	if err != nil {
+		return nil, err
+	}
+	return parseSigningKey(encodedKey)
+}
+
+func parseSigningKey(encodedKey string) (*ecdsa.PrivateKey, error) {
+	rawKey, err := hex.DecodeString(strings.TrimSpace(encodedKey))
+	if err != nil || len(rawKey) != 32 {
+		return nil, fmt.Errorf("The labeler signing key must be a hex encoded 32 byte P-256 private key")
+	}
+
+	curve := elliptic.P256()
+	key := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(rawKey)}
+	key.PublicKey.Curve = curve
+	key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(rawKey)
+	return key, nil
+}
+
+// LabelerDidKey returns the public key of the labeler as did:key, which goes into the #atproto_label verification
+// method of the DID document of the labeler account
+func LabelerDidKey() (string, error) {
+	key, err := labelerSigningKey()
+	if err != nil {
+		return "", err
+	}
+	return didKey(&key.PublicKey), nil
+}
+
+func didKey(key *ecdsa.PublicKey) string {
+	// multicodec prefix of p256-pub
+	publicKey := append([]byte{0x80, 0x24}, elliptic.MarshalCompressed(key.Curve, key.X, key.Y)...)
+	return "did:key:z" + base58Encode(publicKey)
+}
+
+// signLabel signs the DAG-CBOR encoding of the label with a low-S ECDSA signature as atproto requires
+func signLabel(label SignedLabel) (SignedLabel, error) {
+	key, err := labelerSigningKey()
+	if err != nil {
+		return SignedLabel{}, err
+	}
+	return signLabelWithKey(label, key)
+}
+
+func signLabelWithKey(label SignedLabel, key *ecdsa.PrivateKey) (SignedLabel, error) {
+	encoded, err := encodeDagCbor(label.cborValue())
+	if err != nil {
+		return SignedLabel{}, err
+	}
+	hash := sha256.Sum256(encoded)
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return SignedLabel{}, err
+	}
+	order := key.Curve.Params().N
+	if s.Cmp(new(big.Int).Rsh(order, 1)) > 0 {
+		s.Sub(order, s)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	label.Sig = sig
+	return label, nil
+}
+
+// emitBuiltinLabel adds a label or its negation to the built-in labeler if the subject doesn't have that state yet.
+// A label that expires too early is emitted again with the new expiry. Labels are stored unsigned, only the labeler
+// component has the signing key and signs them when it serves them
+func emitBuiltinLabel(targetDid string, val string, neg bool, exp time.Time) error {
+	bskyLabelerDid, err := variables.Get("bsky_labeler_did")
+	if err != nil {
+		return err
+	}
+
+	store, err := kv.OpenStore(labelStore)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	current, found, err := getLabelEvent(store, targetDid, val)
+	if err != nil {
+		return err
+	}
+	isActive := found && !current.Label.Neg
+	if neg && !isActive {
+		LogInfo("Label does not exist", Field("label", val), Field("target", targetDid))
+		return nil
+	}
+	if !neg && isActive && labelIsCurrent(current.Label.Exp, exp) {
+		LogInfo("Label already exists", Field("label", val), Field("target", targetDid))
+		return nil
+	}
+
+	label := SignedLabel{
+		Ver: 1,
+		Src: bskyLabelerDid,
+		Uri: targetDid,
+		Val: val,
+		Neg: neg,
+		Cts: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+	}
+	if !neg && !exp.IsZero() {
+		label.Exp = exp.UTC().Format("2006-01-02T15:04:05.000Z")
+	}
+	id, err := newLabelEventId()
+	if err != nil {
+		return err
+	}
+	event, err := json.Marshal(LabelEvent{Id: id, Label: label})
+	if err != nil {
+		return err
+	}
+	err = store.Set(labelEventKey(targetDid, val), event)
+	if err != nil {
+		return err
+	}
+
+	if neg {
+		LogInfo("Label removed successfully", Field("label", val), Field("target", targetDid))
+	} else {
+		LogInfo("Label added successfully", Field("label", val), Field("target", targetDid))
+	}
+	return nil
+}
+
+func labelEventKey(targetDid string, val string) string {
+	return targetDid + " " + val
+}
+
+// newLabelEventId returns the current time in nanoseconds with a random suffix, so IDs are unique without a counter
+// that all components would have to update
+func newLabelEventId() (string, error) {
+	random := make([]byte, 4)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%019d-%s", time.Now().UnixNano(), hex.EncodeToString(random)), nil
+}
+
+func getLabelEvent(store *kv.Store, targetDid string, val string) (LabelEvent, bool, error) {
+	value, err := store.Get(labelEventKey(targetDid, val))
+	if err != nil {
+		if err.Error() == "no such key" {
+			return LabelEvent{}, false, nil
+		}
+		return LabelEvent{}, false, err
+	}
+	var event LabelEvent
+	err = json.Unmarshal(value, &event)
+	if err != nil {
+		return LabelEvent{}, false, err
+	}
+	return event, true, nil
+}
+
+// loadLabelEvents returns the events of the subjects that match the URI patterns, ordered by ID. Only the keys are
+// listed, events of other subjects are not read
+func loadLabelEvents(store *kv.Store, uriPatterns []string) ([]LabelEvent, error) {
+	keys, err := store.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	events := []LabelEvent{}
+	for _, key := range keys {
+		targetDid, val, found := strings.Cut(key, " ")
+		if !found || !matchesUriPatterns(targetDid, uriPatterns) {
+			continue
+		}
+		event, found, err := getLabelEvent(store, targetDid, val)
+		if err != nil {
+			LogError("Error reading label", Field("key", key), Field("error", err))
+			continue
+		}
+		if found {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Id < events[j].Id })
+	return events, nil
+}
+
+// QueryLabels returns the current label for each subject and value that matches the URI patterns and sources, signed
+// like com.atproto.label.queryLabels returns them. A pattern ending in * matches as prefix. It also returns the cursor
+// for the next page
+func QueryLabels(uriPatterns []string, sources []string, limit int, cursor string) ([]SignedLabel, string, error) {
+	store, err := kv.OpenStore(labelStore)
+	if err != nil {
+		return nil, "", err
+	}
+	defer store.Close()
+
+	events, err := loadLabelEvents(store, uriPatterns)
+	if err != nil {
+		return nil, "", err
+	}
+
+	labels := []SignedLabel{}
+	nextCursor := ""
+	for _, event := range events {
+		if event.Id <= cursor || !matchesSources(event.Label, sources) {
+			continue
+		}
+		if len(labels) >= limit {
+			break
+		}
+		label, err := signLabel(event.Label)
+		if err != nil {
+			return nil, "", err
+		}
+		labels = append(labels, label)
+		nextCursor = event.Id
+	}
+	return labels, nextCursor, nil
+}
+
+// labelStreamDelay keeps the stream behind the newest events, so an event that another component stores a moment
+// later with an older ID is not skipped
+const labelStreamDelay = 10 * time.Second
+
+// Seq is the sequence number of the event in the subscribeLabels stream, the time in nanoseconds its ID starts with
+func (e LabelEvent) Seq() int64 {
+	seq, _ := strconv.ParseInt(strings.SplitN(e.Id, "-", 2)[0], 10, 64)
+	return seq
+}
+
+// LabelFramesResponse are com.atproto.label.subscribeLabels frames, each a DAG-CBOR header followed by a DAG-CBOR
+// message, and the sequence number to continue with. Closed is set if the last frame is an error and the stream ends
+type LabelFramesResponse struct {
+	Cursor int64    `json:"cursor"`
+	Frames [][]byte `json:"frames"`
+	Closed bool     `json:"closed,omitempty"`
+}
+
+// LabelFrames returns the signed events after the cursor as #labels frames. Without a cursor, there are no frames and
+// the sequence number is the current one, so the subscription starts with the next event. A cursor in the future
+// returns a FutureCursor error frame
+func LabelFrames(cursor string, limit int) (LabelFramesResponse, error) {
+	head := time.Now().Add(-labelStreamDelay).UnixNano()
+	if cursor == "" {
+		return LabelFramesResponse{Cursor: head, Frames: [][]byte{}}, nil
+	}
+	seq, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || seq < 0 {
+		return LabelFramesResponse{}, fmt.Errorf("The cursor must be a sequence number")
+	}
+	if seq > time.Now().UnixNano() {
+		frame, err := labelErrorFrame("FutureCursor", "Cursor is in the future")
+		if err != nil {
+			return LabelFramesResponse{}, err
+		}
+		return LabelFramesResponse{Cursor: seq, Frames: [][]byte{frame}, Closed: true}, nil
+	}
+
+	store, err := kv.OpenStore(labelStore)
+	if err != nil {
+		return LabelFramesResponse{}, err
+	}
+	defer store.Close()
+
+	events, err := loadLabelEvents(store, []string{"*"})
+	if err != nil {
+		return LabelFramesResponse{}, err
+	}
+
+	frames := [][]byte{}
+	for _, event := range events {
+		eventSeq := event.Seq()
+		if eventSeq <= seq {
+			continue
+		}
+		if eventSeq > head || len(frames) >= limit {
+			break
+		}
+		label, err := signLabel(event.Label)
+		if err != nil {
+			return LabelFramesResponse{}, err
+		}
+		labelValue := label.cborValue()
+		labelValue["sig"] = []byte(label.Sig)
+		frame, err := labelFrame(map[string]interface{}{"op": 1, "t": "#labels"}, map[string]interface{}{
+			"seq":    eventSeq,
+			"labels": []interface{}{labelValue},
+		})
+		if err != nil {
+			return LabelFramesResponse{}, err
+		}
+		frames = append(frames, frame)
+		seq = eventSeq
+	}
+	if len(frames) < limit && head > seq {
+		// nothing older than head can arrive anymore, so the next request continues from there
+		seq = head
+	}
+	return LabelFramesResponse{Cursor: seq, Frames: frames}, nil
+}
+
+func labelErrorFrame(errorName string, message string) ([]byte, error) {
+	return labelFrame(map[string]interface{}{"op": -1}, map[string]interface{}{"error": errorName, "message": message})
+}
+
+func labelFrame(header map[string]interface{}, body map[string]interface{}) ([]byte, error) {
+	encodedHeader, err := encodeDagCbor(header)
+	if err != nil {
+		return nil, err
+	}
+	encodedBody, err := encodeDagCbor(body)
+	if err != nil {
+		return nil, err
+	}
+	return append(encodedHeader, encodedBody...), nil
+}
+
+func matchesSources(label SignedLabel, sources []string) bool {
+	if len(sources) == 0 {
+		return true
+	}
+	for _, source := range sources {
+		if source == label.Src {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesUriPatterns(uri string, uriPatterns []string) bool {
+	for _, pattern := range uriPatterns {
+		if pattern == "*" || pattern == uri || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(uri, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func base58Encode(input []byte) string {
+	number := new(big.Int).SetBytes(input)
+	base := big.NewInt(58)
+	remainder := new(big.Int)
+	encoded := []byte{}
+	for number.Sign() > 0 {
+		number.DivMod(number, base, remainder)
+		encoded = append(encoded, base58Alphabet[remainder.Int64()])
+	}
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
@@ -0,0 +1,164 @@
+package shared
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// P-256 fixture of the atproto interop tests (crypto/signature-fixtures.json): the DAG-CBOR of {"hello": "world"}
+// signed with a low-S signature by the key of the did:key
+const (
+	fixtureMessageBase64   = "oWVoZWxsb2V3b3JsZA"
+	fixtureDidKey          = "did:key:zDnaembgSGUhZULN2Caob4HLJPaxBh92N7rtH21TErzqf8HQo"
+	fixturePublicKeyHex    = "033a8273eece6b0d82e95c3506617db5000e14ff0023325d0bb0274918bc6a6cdc"
+	fixtureSignatureBase64 = "2vZNsG3UKvvO/CDlrdvyZRISOFylinBh0Jupc6KcWoJWExHptCfduPleDbG3rko3YZnn9Lw0IjpixVmexJDegg"
+)
+
+const testSigningKey = "9f7c5c2a1b3d4e5f60718293a4b5c6d7e8f90123456789abcdef0123456789ab"
+
+func TestEncodeDagCborFixture(t *testing.T) {
+	expected, _ := base64.RawStdEncoding.DecodeString(fixtureMessageBase64)
+	encoded, err := encodeDagCbor(map[string]interface{}{"hello": "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, expected) {
+		t.Errorf("got %x, want %x", encoded, expected)
+	}
+}
+
+func TestEncodeDagCborLabel(t *testing.T) {
+	label := SignedLabel{Ver: 1, Src: "did:plc:abc", Uri: "did:plc:xyz", Val: "mvp", Neg: true, Cts: "2024-01-01T00:00:00.000Z"}
+	// map of 6, the keys of the same length sorted bytewise: cts, neg, src, uri, val, ver. The timestamp is longer
+	// than 23 bytes, so its length takes an extra byte
+	expected := "a6" +
+		"63637473" + "7818" + hex.EncodeToString([]byte("2024-01-01T00:00:00.000Z")) +
+		"636e6567" + "f5" +
+		"63737263" + "6b" + hex.EncodeToString([]byte("did:plc:abc")) +
+		"63757269" + "6b" + hex.EncodeToString([]byte("did:plc:xyz")) +
+		"6376616c" + "636d7670" +
+		"63766572" + "01"
+	encoded, err := encodeDagCbor(label.cborValue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(encoded) != expected {
+		t.Errorf("got %x, want %s", encoded, expected)
+	}
+}
+
+func TestEncodeDagCborKeyOrder(t *testing.T) {
+	// shorter keys first, then bytewise
+	encoded, err := encodeDagCbor(map[string]interface{}{"bb": 1, "a": 2, "ab": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "a3" + "6161" + "02" + "626162" + "03" + "626262" + "01"
+	if hex.EncodeToString(encoded) != expected {
+		t.Errorf("got %x, want %s", encoded, expected)
+	}
+}
+
+func TestFixtureSignatureVerifies(t *testing.T) {
+	rawKey, _ := hex.DecodeString(fixturePublicKeyHex)
+	if encoded := "did:key:z" + base58Encode(append([]byte{0x80, 0x24}, rawKey...)); encoded != fixtureDidKey {
+		t.Fatalf("got %s, want %s", encoded, fixtureDidKey)
+	}
+
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), rawKey)
+	publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	if didKey(publicKey) != fixtureDidKey {
+		t.Errorf("got %s, want %s", didKey(publicKey), fixtureDidKey)
+	}
+	message, _ := base64.RawStdEncoding.DecodeString(fixtureMessageBase64)
+	sig, _ := base64.RawStdEncoding.DecodeString(fixtureSignatureBase64)
+	if !verifySignature(publicKey, message, sig) {
+		t.Error("fixture signature does not verify")
+	}
+}
+
+func TestSignLabelVerifiesWithDidKey(t *testing.T) {
+	key, err := parseSigningKey(testSigningKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	label := SignedLabel{Ver: 1, Src: "did:plc:abc", Uri: "did:plc:xyz", Val: "mvp", Cts: "2024-01-01T00:00:00.000Z", Exp: "2025-01-01T00:00:00.000Z"}
+
+	// the signature is random, so sign a few times to hit high S values that have to be flipped
+	for i := 0; i < 20; i++ {
+		signed, err := signLabelWithKey(label, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(signed.Sig) != 64 {
+			t.Fatalf("signature has %d bytes, want 64", len(signed.Sig))
+		}
+		halfOrder := new(big.Int).Rsh(elliptic.P256().Params().N, 1)
+		if new(big.Int).SetBytes(signed.Sig[32:]).Cmp(halfOrder) > 0 {
+			t.Fatal("signature is not low-S")
+		}
+
+		// consumers resolve the key from the did:key and verify the DAG-CBOR of the label without the signature
+		publicKey := publicKeyOfDidKey(t, didKey(&key.PublicKey))
+		sig := signed.Sig
+		signed.Sig = nil
+		encoded, err := encodeDagCbor(signed.cborValue())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !verifySignature(publicKey, encoded, sig) {
+			t.Fatal("label signature does not verify")
+		}
+	}
+}
+
+func TestBase58Encode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"00", "1"},
+		{"0000287fb4cd", "11233QC4"},
+		{hex.EncodeToString([]byte("Hello World!")), "2NEpo7TZRRrLZSi2U"},
+	}
+	for _, test := range tests {
+		input, _ := hex.DecodeString(test.input)
+		if encoded := base58Encode(input); encoded != test.expected {
+			t.Errorf("base58Encode(%s) = %s, want %s", test.input, encoded, test.expected)
+		}
+	}
+}
+
+func verifySignature(publicKey *ecdsa.PublicKey, message []byte, sig []byte) bool {
+	if len(sig) != 64 {
+		return false
+	}
+	hash := sha256.Sum256(message)
+	return ecdsa.Verify(publicKey, hash[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+}
+
+// publicKeyOfDidKey decodes a did:key of a P-256 key like consumers of the labels do
+func publicKeyOfDidKey(t *testing.T, didKey string) *ecdsa.PublicKey {
+	number := new(big.Int)
+	for _, char := range strings.TrimPrefix(didKey, "did:key:z") {
+		number.Mul(number, big.NewInt(58))
+		number.Add(number, big.NewInt(int64(strings.IndexRune(base58Alphabet, char))))
+	}
+	decoded := number.Bytes()
+	if len(decoded) != 35 || decoded[0] != 0x80 || decoded[1] != 0x24 {
+		t.Fatalf("%s is not a P-256 did:key", didKey)
+	}
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), decoded[2:])
+	if x == nil {
+		t.Fatalf("%s does not contain a valid point", didKey)
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+}
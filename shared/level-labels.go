@@ -0,0 +1,91 @@
+package shared
+
+import (
+	"sort"
+	"strings"
+)
+
+// LevelLabel returns the label for a first level of the module, e.g. oracleace-director. Everything that is not a
+// letter becomes a hyphen, as label values only allow lowercase letters and hyphens
+func (m ModuleSpecifics) LevelLabel(firstLevel string) string {
+	var builder strings.Builder
+	builder.WriteString(m.ModuleKey)
+	builder.WriteString("-")
+	hyphen := true
+	for _, r := range strings.ToLower(firstLevel) {
+		if r >= 'a' && r <= 'z' {
+			builder.WriteRune(r)
+			hyphen = false
+		} else if !hyphen {
+			builder.WriteRune('-')
+			hyphen = true
+		}
+	}
+	label := strings.TrimRight(builder.String(), "-")
+	if len(label) > 100 {
+		label = strings.TrimRight(label[:100], "-")
+	}
+	return label
+}
+
+// LevelLabels returns the labels for the first levels of a naming, sorted and without duplicates. It is empty if the
+// module doesn't have level labels
+func (m ModuleSpecifics) LevelLabels(naming Naming) []string {
+	firstLevels := []string{}
+	for first := range naming.FirstAndSecondLevel {
+		// the description contains the first level as it is, the title may be shortened or translated
+		firstLevels = append(firstLevels, strings.TrimPrefix(first.Description, naming.Description+": "))
+	}
+	return m.levelLabelsFor(firstLevels)
+}
+
+// AllLevelLabels returns the labels for all first levels the module knows upfront
+func (m ModuleSpecifics) AllLevelLabels() []string {
+	firstLevels := []string{}
+	for first := range m.FirstAndSecondLevel {
+		firstLevels = append(firstLevels, first)
+	}
+	return m.levelLabelsFor(firstLevels)
+}
+
+func (m ModuleSpecifics) levelLabelsFor(firstLevels []string) []string {
+	labels := []string{}
+	if !m.LevelLabelsEnabled {
+		return labels
+	}
+	seen := map[string]bool{}
+	for _, first := range firstLevels {
+		label := m.LevelLabel(first)
+		if label == m.ModuleKey || seen[label] {
+			continue
+		}
+		seen[label] = true
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// StoredLevelLabels returns the level labels that were applied for a stored verification. Entries written before level
+// labels were stored could have any of the labels of the module
+func (m ModuleSpecifics) StoredLevelLabels(entry StoreEntry) []string {
+	if entry.LevelLabels != nil {
+		return entry.LevelLabels
+	}
+	return m.AllLevelLabels()
+}
+
+// staleLabels returns the labels that are in previous but not in current
+func staleLabels(previous []string, current []string) []string {
+	keep := map[string]bool{}
+	for _, label := range current {
+		keep[label] = true
+	}
+	stale := []string{}
+	for _, label := range previous {
+		if !keep[label] {
+			stale = append(stale, label)
+		}
+	}
+	return stale
+}
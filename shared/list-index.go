@@ -0,0 +1,244 @@
+package shared
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/fermyon/spin/sdk/go/v2/kv"
+	"github.com/fermyon/spin/sdk/go/v2/variables"
+)
+
+// a list is indexed if listindex-<list URI> exists, each member is stored as listindex-<list URI>-<member DID> with the
+// rkey of its listitem. One key per member keeps concurrent changes of the same list from overwriting each other
+const listIndexKeyPrefix = "listindex-"
+
+type ListRecordsResponse struct {
+	Records []struct {
+		URI   string         `json:"uri"`
+		Value ListItemRecord `json:"value"`
+	} `json:"records"`
+	Cursor string `json:"cursor"`
+}
+
+type ApplyWritesResponse struct {
+	Results []struct {
+		Type string `json:"$type"`
+		URI  string `json:"uri"`
+	} `json:"results"`
+}
+
+func listIndexKey(listUri string) string {
+	return listIndexKeyPrefix + listUri
+}
+
+func listIndexMemberKey(listUri string, memberDid string) string {
+	return listIndexKey(listUri) + "-" + memberDid
+}
+
+func isListIndexed(store *kv.Store, listUri string) (bool, error) {
+	value, err := store.Get(listIndexKey(listUri))
+	if err != nil {
+		if err.Error() == "no such key" {
+			return false, nil
+		}
+		return false, err
+	}
+	// an index with all members in one value is from an earlier version and ignored until the index is rebuilt
+	return len(value) == 0, nil
+}
+
+// GetListIndexEntry returns the rkey of the listitem of a member. The first bool is false if the list is not indexed,
+// the second one if the member is not on the list according to the index
+func GetListIndexEntry(listUri string, memberDid string) (string, bool, bool, error) {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return "", false, false, err
+	}
+	defer store.Close()
+
+	indexed, err := isListIndexed(store, listUri)
+	if err != nil || !indexed {
+		return "", false, false, err
+	}
+	value, err := store.Get(listIndexMemberKey(listUri, memberDid))
+	if err != nil {
+		if err.Error() == "no such key" {
+			return "", true, false, nil
+		}
+		return "", true, false, err
+	}
+	return string(value), true, true, nil
+}
+
+// setListIndex replaces the index of a list. keys are all keys of the store, to find the members that are gone
+func setListIndex(store *kv.Store, keys []string, listUri string, members map[string]string) error {
+	memberPrefix := listIndexKey(listUri) + "-"
+	for _, key := range keys {
+		if strings.HasPrefix(key, memberPrefix) {
+			if _, ok := members[strings.TrimPrefix(key, memberPrefix)]; ok {
+				continue
+			}
+			err := store.Delete(key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := store.Set(listIndexKey(listUri), []byte{})
+	if err != nil {
+		return err
+	}
+	for memberDid, rkey := range members {
+		err = store.Set(listIndexMemberKey(listUri, memberDid), []byte(rkey))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceListIndex replaces the index of a list if it is indexed
+func replaceListIndex(listUri string, members map[string]string) error {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	indexed, err := isListIndexed(store, listUri)
+	if err != nil || !indexed {
+		return err
+	}
+	keys, err := store.GetKeys()
+	if err != nil {
+		return err
+	}
+	return setListIndex(store, keys, listUri, members)
+}
+
+// updateListIndex changes the index of a list if it exists. An empty rkey removes the member
+func updateListIndex(listUri string, memberDid string, rkey string) {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		LogError("Error updating index of list", Field("list", listUri), Field("error", err))
+		return
+	}
+	defer store.Close()
+
+	indexed, err := isListIndexed(store, listUri)
+	if err != nil || !indexed {
+		return
+	}
+	if rkey == "" {
+		err = store.Delete(listIndexMemberKey(listUri, memberDid))
+	} else {
+		err = store.Set(listIndexMemberKey(listUri, memberDid), []byte(rkey))
+	}
+	if err != nil {
+		LogError("Error updating index of list", Field("list", listUri), Field("error", err))
+	}
+}
+
+func deleteListIndex(listUri string) {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return
+	}
+	defer store.Close()
+	keys, err := store.GetKeys()
+	if err != nil {
+		LogError("Error deleting index of list", Field("list", listUri), Field("error", err))
+		return
+	}
+	for _, key := range keys {
+		if key == listIndexKey(listUri) || strings.HasPrefix(key, listIndexKey(listUri)+"-") {
+			err = store.Delete(key)
+			if err != nil {
+				LogError("Error deleting index of list", Field("list", listUri), Field("error", err))
+			}
+		}
+	}
+}
+
+// InitListIndex marks a list that was just created as indexed, it has no members yet
+func InitListIndex(listUri string) {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		LogError("Error creating index of list", Field("list", listUri), Field("error", err))
+		return
+	}
+	defer store.Close()
+	err = store.Set(listIndexKey(listUri), []byte{})
+	if err != nil {
+		LogError("Error creating index of list", Field("list", listUri), Field("error", err))
+	}
+}
+
+// RebuildListIndex reads all listitem records from the repo of the service account and replaces the index of all its
+// lists and starter packs. It returns the number of indexed lists
+func RebuildListIndex(accessJwt string, endpoint string) (int, error) {
+	bskyDid, err := variables.Get("bsky_did")
+	if err != nil {
+		return 0, err
+	}
+	LogInfo("Rebuilding the list index", Field("repo", bskyDid))
+
+	// lists without members need an empty index as well
+	index := map[string]map[string]string{}
+	lists, err := GetLists(accessJwt, endpoint)
+	if err != nil {
+		return 0, err
+	}
+	for _, list := range lists {
+		index[list.URI] = map[string]string{}
+	}
+	starterPacks, err := GetStarterPacks(accessJwt, endpoint)
+	if err != nil {
+		return 0, err
+	}
+	for _, starterPack := range starterPacks {
+		index[starterPack.Record.List] = map[string]string{}
+	}
+
+	client := NewXrpcClient(endpoint, accessJwt)
+	params := url.Values{"repo": {bskyDid}, "collection": {"app.bsky.graph.listitem"}, "limit": {"100"}}
+	for {
+		var response ListRecordsResponse
+		err = client.Query("com.atproto.repo.listRecords", params, &response)
+		if err != nil {
+			return 0, err
+		}
+		for _, record := range response.Records {
+			members, ok := index[record.Value.List]
+			if !ok {
+				// listitem of a deleted list
+				continue
+			}
+			members[record.Value.Subject] = record.URI[strings.LastIndex(record.URI, "/")+1:]
+		}
+
+		if response.Cursor == "" {
+			break
+		}
+		params.Set("cursor", response.Cursor)
+	}
+
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return 0, err
+	}
+	defer store.Close()
+	keys, err := store.GetKeys()
+	if err != nil {
+		return 0, err
+	}
+	for listUri, members := range index {
+		err = setListIndex(store, keys, listUri, members)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	LogInfo("Indexed lists", Field("count", len(index)))
+	return len(index), nil
+}
@@ -0,0 +1,192 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fermyon/spin/sdk/go/v2/variables"
+)
+
+// Log levels, configured with the Spin variable log_level
+const (
+	LogLevelDebug = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+const redacted = "[redacted]"
+
+// keys of JSON payloads and query strings that are never logged. text covers posts and direct messages
+var sensitiveKeys = map[string]bool{
+	"password":   true,
+	"accessjwt":  true,
+	"refreshjwt": true,
+	"token":      true,
+	"text":       true,
+}
+
+var jwtPattern = regexp.MustCompile(`eyJ[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]*`)
+
+// LogField is a structured field of a log line
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+func Field(key string, value interface{}) LogField {
+	return LogField{Key: key, Value: value}
+}
+
+// configured level and secrets are read once per component instance
+var (
+	logLevel   = -1
+	logSecrets []string
+)
+
+func currentLogLevel() int {
+	if logLevel >= 0 {
+		return logLevel
+	}
+	logLevel = LogLevelInfo
+	if value, err := variables.Get("log_level"); err == nil {
+		for level, name := range logLevelNames {
+			if strings.EqualFold(strings.TrimSpace(value), name) {
+				logLevel = level
+			}
+		}
+	}
+	// the password is part of the admin URLs, so every occurrence of it and the signing key is removed
+	for _, secret := range []string{"bsky_password", "labeler_signing_key"} {
+		if value, err := variables.Get(secret); err == nil && value != "" {
+			logSecrets = append(logSecrets, value)
+		}
+	}
+	return logLevel
+}
+
+func LogDebug(msg string, fields ...LogField) {
+	writeLog(LogLevelDebug, msg, fields)
+}
+
+func LogInfo(msg string, fields ...LogField) {
+	writeLog(LogLevelInfo, msg, fields)
+}
+
+func LogWarn(msg string, fields ...LogField) {
+	writeLog(LogLevelWarn, msg, fields)
+}
+
+func LogError(msg string, fields ...LogField) {
+	writeLog(LogLevelError, msg, fields)
+}
+
+// writeLog prints one JSON object per line with the redacted message and fields
+func writeLog(level int, msg string, fields []LogField) {
+	if level < currentLogLevel() {
+		return
+	}
+	line := map[string]interface{}{
+		"time":  time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		"level": logLevelNames[level],
+		"msg":   Redact(msg),
+	}
+	for _, field := range fields {
+		if sensitiveKeys[strings.ToLower(field.Key)] {
+			line[field.Key] = redacted
+			continue
+		}
+		switch value := field.Value.(type) {
+		case string:
+			line[field.Key] = Redact(value)
+		case error:
+			line[field.Key] = Redact(value.Error())
+		default:
+			line[field.Key] = value
+		}
+	}
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		fmt.Println(logLevelNames[level] + ": " + Redact(msg))
+		return
+	}
+	fmt.Println(string(encoded))
+}
+
+// Redact removes JWTs and configured secrets like the bot password from a text
+func Redact(text string) string {
+	currentLogLevel()
+	text = jwtPattern.ReplaceAllString(text, redacted)
+	for _, secret := range logSecrets {
+		text = strings.ReplaceAll(text, secret, redacted)
+		text = strings.ReplaceAll(text, url.PathEscape(secret), redacted)
+	}
+	return text
+}
+
+// routeWithPassword checks if the last path segment of a route can be the password, e.g. /weekly-validation/<did>/<pwd>
+// or a PUT to /validate-mvp/<pwd>
+func routeWithPassword(path string) bool {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 2 {
+		return false
+	}
+	return segments[0] == "admin" || segments[0] == "weekly-validation" || strings.HasPrefix(segments[0], "validate-")
+}
+
+// RedactUrl removes secrets, sensitive query parameters and the password segment of the routes that take one
+func RedactUrl(rawUrl string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return Redact(rawUrl)
+	}
+	if routeWithPassword(parsed.Path) {
+		parsed.Path = parsed.Path[:strings.LastIndex(strings.TrimSuffix(parsed.Path, "/"), "/")+1] + "pwd"
+		parsed.RawPath = ""
+	}
+	query := parsed.Query()
+	for key := range query {
+		if sensitiveKeys[strings.ToLower(key)] {
+			query.Set(key, redacted)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return Redact(parsed.String())
+}
+
+// RedactPayload replaces the values of sensitive keys in a JSON payload. Other payloads are only logged with their size
+func RedactPayload(payload string) string {
+	var decoded interface{}
+	if json.Unmarshal([]byte(payload), &decoded) != nil {
+		return fmt.Sprintf("[%d bytes]", len(payload))
+	}
+	encoded, err := json.Marshal(redactValue(decoded))
+	if err != nil {
+		return fmt.Sprintf("[%d bytes]", len(payload))
+	}
+	return Redact(string(encoded))
+}
+
+func redactValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range typed {
+			if sensitiveKeys[strings.ToLower(key)] {
+				typed[key] = redacted
+			} else {
+				typed[key] = redactValue(inner)
+			}
+		}
+	case []interface{}:
+		for i, inner := range typed {
+			typed[i] = redactValue(inner)
+		}
+	}
+	return value
+}
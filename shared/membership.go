@@ -0,0 +1,382 @@
+package shared
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/fermyon/spin/sdk/go/v2/variables"
+)
+
+// MaxApplyWrites is the number of writes sent in one applyWrites batch, the PDS accepts up to 200
+const MaxApplyWrites = 100
+
+// Status of a membership change
+const (
+	MembershipPending       = "pending"
+	MembershipAdded         = "added"
+	MembershipAlreadyMember = "already-member"
+	MembershipFailed        = "failed"
+)
+
+// MembershipChange adds a user to one list or starter pack
+type MembershipChange struct {
+	Title string `json:"title"`
+	Kind  string `json:"kind"`
+	URI   string `json:"uri"`
+	// the list that holds the members, for starter packs this differs from the URI
+	ListURI string `json:"listUri"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	writes  []ApplyWritesWrite
+}
+
+// MembershipResult reports for every list and starter pack if the user was added to it
+type MembershipResult struct {
+	Changes []MembershipChange `json:"changes"`
+}
+
+// Succeeded returns the lists and starter packs the user is on now
+func (r MembershipResult) Succeeded(bskyHandleOwner string) []ListOrStarterPackWithUrl {
+	elements := make([]ListOrStarterPackWithUrl, 0)
+	for _, change := range r.Changes {
+		if change.Status == MembershipAdded || change.Status == MembershipAlreadyMember {
+			elements = append(elements, ConvertToStruct(change.URI, change.Title, change.Kind, bskyHandleOwner))
+		}
+	}
+	return elements
+}
+
+// Failed returns the changes that could not be applied
+func (r MembershipResult) Failed() []MembershipChange {
+	failed := make([]MembershipChange, 0)
+	for _, change := range r.Changes {
+		if change.Status == MembershipFailed {
+			failed = append(failed, change)
+		}
+	}
+	return failed
+}
+
+// Err combines the errors of the failed changes or returns nil if all succeeded
+func (r MembershipResult) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	messages := make([]string, len(failed))
+	for i, change := range failed {
+		messages[i] = change.Kind + " " + change.Title + ": " + change.Error
+	}
+	return fmt.Errorf("Error adding user to %d of %d lists and starter packs: %s", len(failed), len(r.Changes), strings.Join(messages, "; "))
+}
+
+func failedChange(title string, kind string, err error) MembershipChange {
+	return MembershipChange{Title: title, Kind: kind, Status: MembershipFailed, Error: err.Error()}
+}
+
+// PlanStarterPackMembership finds the starter pack with the title that has space left, or creates a new one, and prepares
+// the writes that add the user to its list and update the starter pack record
+func PlanStarterPackMembership(bskyDid string, starterPackTitle string, starterPackDescription string, starterPacks []StarterPack, timestamp string, accessJwt string, endpoint string) MembershipChange {
+	LogDebug("Planning to add user to the right starter pack", Field("title", starterPackTitle), Field("description", starterPackDescription))
+	matchingStarterPacks := []StarterPack{}
+	for _, sp := range starterPacks {
+		if sp.Record.Name == starterPackTitle {
+			matchingStarterPacks = append(matchingStarterPacks, sp)
+		}
+	}
+	LogDebug("Found matching starter packs", Field("count", len(matchingStarterPacks)))
+
+	if len(matchingStarterPacks) == 0 {
+		LogWarn("No matching starter pack found", Field("title", starterPackTitle))
+		return failedChange(starterPackTitle, "sp", fmt.Errorf("No matching starter pack found with title: "+starterPackTitle))
+	}
+
+	var target *StarterPack
+	for i, sp := range matchingStarterPacks {
+		list, err := GetList(sp.Record.List, accessJwt, endpoint)
+		if err != nil {
+			return failedChange(starterPackTitle, "sp", err)
+		}
+
+		LogDebug("Found existing starter pack", Field("title", starterPackTitle), Field("itemCount", list.ListItemCount))
+		userOnList, err := CheckOrDeleteUserOnList(sp.Record.List, bskyDid, false, accessJwt, endpoint)
+		if err != nil {
+			return failedChange(starterPackTitle, "sp", fmt.Errorf("Error checking if user is on list: "+err.Error()))
+		}
+		if userOnList {
+			LogDebug("User is already on existing starter pack", Field("title", starterPackTitle))
+			return MembershipChange{Title: starterPackTitle, Kind: "sp", URI: sp.URI, ListURI: sp.Record.List, Status: MembershipAlreadyMember}
+		}
+		if list.ListItemCount < 149 {
+			LogDebug("Found existing starter pack with space left", Field("title", starterPackTitle))
+			target = &matchingStarterPacks[i]
+			break
+		} else {
+			LogDebug("Found existing starter pack but it's full", Field("title", starterPackTitle))
+		}
+	}
+
+	// we found matching starter packs but none of them had space left
+	if target == nil {
+		LogInfo("Starter pack list is full, creating a new one", Field("title", starterPackTitle))
+		newListResponse, newStarterPackResponse, err := CreateStarterPack(starterPackTitle, starterPackDescription, timestamp, accessJwt, endpoint)
+		if err != nil {
+			return failedChange(starterPackTitle, "sp", err)
+		}
+		target = &StarterPack{
+			URI:    newStarterPackResponse.URI,
+			Record: Record{Name: starterPackTitle, Description: starterPackDescription, List: newListResponse.URI, CreatedAt: timestamp},
+		}
+		LogInfo("Created new list and starter pack", Field("title", starterPackTitle))
+	}
+
+	return MembershipChange{
+		Title:   starterPackTitle,
+		Kind:    "sp",
+		URI:     target.URI,
+		ListURI: target.Record.List,
+		Status:  MembershipPending,
+		writes: []ApplyWritesWrite{
+			listItemWrite(bskyDid, target.Record.List, timestamp),
+			{
+				Type:       "com.atproto.repo.applyWrites#update",
+				Collection: "app.bsky.graph.starterpack",
+				Rkey:       target.URI[strings.LastIndex(target.URI, "/")+1:],
+				Value: StarterPackRecord{
+					Type:        "app.bsky.graph.starterpack",
+					Name:        target.Record.Name,
+					Description: target.Record.Description,
+					List:        target.Record.List,
+					Feeds:       []interface{}{},
+					CreatedAt:   target.Record.CreatedAt,
+					UpdatedAt:   timestamp,
+				},
+			},
+		},
+	}
+}
+
+// PlanListMembership finds the list with the title and prepares the write that adds the user to it, unless the user is already on it
+func PlanListMembership(bskyDid string, listTitle string, lists []List, timestamp string, accessJwt string, endpoint string) MembershipChange {
+	LogDebug("Planning to add user to the right list", Field("title", listTitle))
+	for _, list := range lists {
+		if list.Name == listTitle {
+			LogDebug("Found existing list", Field("title", listTitle))
+			userOnList, err := CheckOrDeleteUserOnList(list.URI, bskyDid, false, accessJwt, endpoint)
+			if err != nil {
+				return failedChange(listTitle, "list", fmt.Errorf("Error checking if user is on list: "+err.Error()))
+			}
+			if userOnList {
+				LogDebug("User is already on existing list", Field("title", listTitle))
+				return MembershipChange{Title: listTitle, Kind: "list", URI: list.URI, ListURI: list.URI, Status: MembershipAlreadyMember}
+			}
+			return MembershipChange{
+				Title:   listTitle,
+				Kind:    "list",
+				URI:     list.URI,
+				ListURI: list.URI,
+				Status:  MembershipPending,
+				writes:  []ApplyWritesWrite{listItemWrite(bskyDid, list.URI, timestamp)},
+			}
+		}
+	}
+
+	LogWarn("No matching list found", Field("title", listTitle))
+	return failedChange(listTitle, "list", fmt.Errorf("No matching list found with title: "+listTitle))
+}
+
+func listItemWrite(userToAddDid string, listUri string, timestamp string) ApplyWritesWrite {
+	return ApplyWritesWrite{
+		Type:       "com.atproto.repo.applyWrites#create",
+		Collection: "app.bsky.graph.listitem",
+		Value: ListItemRecord{
+			Type:      "app.bsky.graph.listitem",
+			Subject:   userToAddDid,
+			List:      listUri,
+			CreatedAt: timestamp,
+		},
+	}
+}
+
+// ApplyMembershipChanges sends the writes of the pending changes in applyWrites batches. A batch is applied completely
+// or not at all, so if one fails, its changes are retried one by one to find out exactly which ones fail
+func ApplyMembershipChanges(changes []MembershipChange, accessJwt string, endpoint string) MembershipResult {
+	bskyDid, err := variables.Get("bsky_did")
+	if err != nil {
+		for i := range changes {
+			if changes[i].Status == MembershipPending {
+				changes[i].Status = MembershipFailed
+				changes[i].Error = err.Error()
+			}
+		}
+		return MembershipResult{Changes: changes}
+	}
+
+	client := NewXrpcClient(endpoint, accessJwt)
+	batch := []int{}
+	writeCount := 0
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := applyBatch(client, bskyDid, changes, batch)
+		if err != nil && len(batch) > 1 {
+			LogWarn("Batch of changes failed, applying them one by one", Field("count", len(batch)), Field("error", err))
+			for _, index := range batch {
+				applyBatch(client, bskyDid, changes, []int{index})
+			}
+		}
+		batch = []int{}
+		writeCount = 0
+	}
+
+	for i, change := range changes {
+		if change.Status != MembershipPending {
+			continue
+		}
+		if writeCount+len(change.writes) > MaxApplyWrites {
+			flush()
+		}
+		batch = append(batch, i)
+		writeCount += len(change.writes)
+	}
+	flush()
+
+	return MembershipResult{Changes: changes}
+}
+
+func applyBatch(client *XrpcClient, bskyDid string, changes []MembershipChange, batch []int) error {
+	request := ApplyWritesRequest{Repo: bskyDid, Writes: []ApplyWritesWrite{}}
+	for _, index := range batch {
+		request.Writes = append(request.Writes, changes[index].writes...)
+	}
+
+	var response ApplyWritesResponse
+	err := client.Procedure("com.atproto.repo.applyWrites", request, &response)
+	if err == nil {
+		indexCreatedListItems(request.Writes, response)
+	}
+	for _, index := range batch {
+		if err != nil {
+			changes[index].Status = MembershipFailed
+			changes[index].Error = err.Error()
+		} else {
+			changes[index].Status = MembershipAdded
+			changes[index].Error = ""
+		}
+	}
+	return err
+}
+
+// indexCreatedListItems adds the listitems to the list index, the results are in the order of the writes
+func indexCreatedListItems(writes []ApplyWritesWrite, response ApplyWritesResponse) {
+	for i, write := range writes {
+		listItem, ok := write.Value.(ListItemRecord)
+		if !ok || write.Type != "com.atproto.repo.applyWrites#create" || i >= len(response.Results) {
+			continue
+		}
+		createdUri := response.Results[i].URI
+		updateListIndex(listItem.List, listItem.Subject, createdUri[strings.LastIndex(createdUri, "/")+1:])
+	}
+}
+
+// GetListItems returns all items of a list
+func GetListItems(listUri string, accessJwt string, endpoint string) ([]Item, error) {
+	client := NewAppViewClient(endpoint, accessJwt)
+	items := []Item{}
+	params := url.Values{"list": {listUri}, "limit": {"100"}}
+	for {
+		var response ListResponse
+		err := client.Query("app.bsky.graph.getList", params, &response)
+		if err != nil {
+			return []Item{}, err
+		}
+		items = append(items, response.Items...)
+
+		if response.Cursor == "" {
+			break
+		}
+		params.Set("cursor", response.Cursor)
+	}
+	return items, nil
+}
+
+// DedupeListItems removes listitems of users that are on a list more than once from all lists and starter packs
+// of the service account, keeping one of them. It returns the number of removed listitems
+func DedupeListItems(accessJwt string, endpoint string) (int, error) {
+	bskyDid, err := variables.Get("bsky_did")
+	if err != nil {
+		return 0, err
+	}
+
+	lists, err := GetLists(accessJwt, endpoint)
+	if err != nil {
+		return 0, err
+	}
+	starterPacks, err := GetStarterPacks(accessJwt, endpoint)
+	if err != nil {
+		return 0, err
+	}
+	listUris := []string{}
+	seen := map[string]bool{}
+	for _, list := range lists {
+		if !seen[list.URI] {
+			seen[list.URI] = true
+			listUris = append(listUris, list.URI)
+		}
+	}
+	for _, starterPack := range starterPacks {
+		if !seen[starterPack.Record.List] {
+			seen[starterPack.Record.List] = true
+			listUris = append(listUris, starterPack.Record.List)
+		}
+	}
+
+	client := NewXrpcClient(endpoint, accessJwt)
+	removed := 0
+	for _, listUri := range listUris {
+		items, err := GetListItems(listUri, accessJwt, endpoint)
+		if err != nil {
+			return removed, err
+		}
+
+		writes := []ApplyWritesWrite{}
+		members := map[string]string{}
+		for _, item := range items {
+			if _, seen := members[item.Subject.DID]; !seen {
+				members[item.Subject.DID] = item.URI[strings.LastIndex(item.URI, "/")+1:]
+				continue
+			}
+			LogInfo("Removing duplicate listitem", Field("uri", item.URI), Field("did", item.Subject.DID))
+			writes = append(writes, ApplyWritesWrite{
+				Type:       "com.atproto.repo.applyWrites#delete",
+				Collection: "app.bsky.graph.listitem",
+				Rkey:       item.URI[strings.LastIndex(item.URI, "/")+1:],
+			})
+		}
+
+		for start := 0; start < len(writes); start += MaxApplyWrites {
+			end := start + MaxApplyWrites
+			if end > len(writes) {
+				end = len(writes)
+			}
+			err = client.Procedure("com.atproto.repo.applyWrites", ApplyWritesRequest{Repo: bskyDid, Writes: writes[start:end]}, nil)
+			if err != nil {
+				return removed, err
+			}
+			removed += end - start
+		}
+
+		// the index may point to one of the removed listitems
+		if len(writes) > 0 {
+			err = replaceListIndex(listUri, members)
+			if err != nil {
+				return removed, err
+			}
+		}
+	}
+
+	LogInfo("Removed duplicate listitems", Field("count", removed))
+	return removed, nil
+}
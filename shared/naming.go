@@ -7,6 +7,7 @@ import (
 type ValidationRequest struct {
 	BskyHandle string `json:"bskyHandle"`
 	VerificationId       string `json:"verificationId"`
+	ProofMode  string `json:"proofMode,omitempty"`
 }
 
 type TitleAndDescription struct {
@@ -0,0 +1,494 @@
+package shared
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fermyon/spin/sdk/go/v2/kv"
+	"github.com/fermyon/spin/sdk/go/v2/variables"
+)
+
+// Steps of the verification pipeline, in the order they run
+const (
+	StepStore        = "store"
+	StepLists        = "lists"
+	StepStarterPacks = "starter-packs"
+	StepFollow       = "follow"
+	StepLabel        = "label"
+)
+
+var pipelineSteps = []string{StepStore, StepLists, StepStarterPacks, StepFollow, StepLabel}
+
+const pendingKeyPrefix = "pending-"
+
+// PendingVerification is stored when a failed verification could not be rolled back, so an admin can retry it
+type PendingVerification struct {
+	ModuleKey      string         `json:"moduleKey"`
+	VerificationId string         `json:"verificationId"`
+	Did            string         `json:"did"`
+	Handle         string         `json:"handle"`
+	ProofMode      string         `json:"proofMode,omitempty"`
+	Label          string         `json:"label"`
+	LevelLabels    []string       `json:"levelLabels,omitempty"`
+	Title          string         `json:"title"`
+	Description    string         `json:"description"`
+	Levels         []PendingLevel `json:"levels"`
+	// the k/v entry before the store step, restored on rollback
+	PreviousEntry  string   `json:"previousEntry,omitempty"`
+	CompletedSteps []string `json:"completedSteps"`
+	FailedStep     string   `json:"failedStep"`
+	Error          string   `json:"error"`
+	Attempts       int      `json:"attempts"`
+	UpdatedAt      string   `json:"updatedAt"`
+}
+
+type PendingLevel struct {
+	First  TitleAndDescription   `json:"first"`
+	Second []TitleAndDescription `json:"second"`
+}
+
+// PipelineError is returned when a step failed. Either all previous steps were rolled back or a pending entry was stored
+type PipelineError struct {
+	Step       string
+	Err        error
+	RolledBack bool
+	PendingKey string
+}
+
+func (e *PipelineError) Error() string {
+	if e.RolledBack {
+		return "Verification failed at step " + e.Step + " and was rolled back: " + e.Err.Error()
+	}
+	return "Verification failed at step " + e.Step + " and could not be rolled back, it is stored as " + e.PendingKey + " to be retried: " + e.Err.Error()
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+func pendingKey(moduleKey string, verificationId string) string {
+	return pendingKeyPrefix + moduleKey + "-" + verificationId
+}
+
+type verificationPipeline struct {
+	pending   PendingVerification
+	naming    Naming
+	accessJwt string
+	endpoint  string
+	// the membership changes of the list and starter pack steps, to remove the user again on rollback
+	changes map[string][]MembershipChange
+}
+
+func newVerificationPipeline(pending PendingVerification, accessJwt string, endpoint string) *verificationPipeline {
+	naming := Naming{
+		Key:                 pending.ModuleKey,
+		Title:               pending.Title,
+		Description:         pending.Description,
+		FirstAndSecondLevel: map[TitleAndDescription][]TitleAndDescription{},
+	}
+	for _, level := range pending.Levels {
+		naming.FirstAndSecondLevel[level.First] = level.Second
+	}
+	return &verificationPipeline{pending: pending, naming: naming, accessJwt: accessJwt, endpoint: endpoint, changes: map[string][]MembershipChange{}}
+}
+
+// RunVerificationPipeline stores the verification, adds the user to the lists and starter packs, follows and labels them
+// with the module label and the level labels. If a step fails, the completed steps are rolled back. If that fails as
+// well, a pending entry is stored that RetryPendingVerifications can complete later
+func RunVerificationPipeline(naming Naming, verificationId string, bskyDid string, bskyHandle string, proofMode string, label string, levelLabels []string, accessJwt string, endpoint string) ([]ListOrStarterPackWithUrl, error) {
+	pending := newPendingVerification(naming, verificationId, bskyDid, bskyHandle, proofMode, label, levelLabels)
+	p := newVerificationPipeline(pending, accessJwt, endpoint)
+	return p.run()
+}
+
+// newPendingVerification keeps everything the pipeline needs, so it can run without the module later
+func newPendingVerification(naming Naming, verificationId string, bskyDid string, bskyHandle string, proofMode string, label string, levelLabels []string) PendingVerification {
+	pending := PendingVerification{
+		ModuleKey:      naming.Key,
+		VerificationId: verificationId,
+		Did:            bskyDid,
+		Handle:         bskyHandle,
+		ProofMode:      proofMode,
+		Label:          label,
+		LevelLabels:    levelLabels,
+		Title:          naming.Title,
+		Description:    naming.Description,
+		Levels:         []PendingLevel{},
+		CompletedSteps: []string{},
+	}
+	for first, secondArray := range naming.FirstAndSecondLevel {
+		pending.Levels = append(pending.Levels, PendingLevel{First: first, Second: secondArray})
+	}
+	return pending
+}
+
+func (p *verificationPipeline) completed(step string) bool {
+	for _, completedStep := range p.pending.CompletedSteps {
+		if completedStep == step {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *verificationPipeline) run() ([]ListOrStarterPackWithUrl, error) {
+	for _, step := range pipelineSteps {
+		if p.completed(step) {
+			continue
+		}
+		LogInfo("Running verification step", Field("step", step), Field("did", p.pending.Did))
+		err := p.runStep(step)
+		if err != nil {
+			LogError("Verification step failed", Field("step", step), Field("did", p.pending.Did), Field("error", err))
+			return []ListOrStarterPackWithUrl{}, p.rollback(step, err)
+		}
+		p.pending.CompletedSteps = append(p.pending.CompletedSteps, step)
+	}
+
+	bskyHandleOwner, err := variables.Get("bsky_handle")
+	if err != nil {
+		return []ListOrStarterPackWithUrl{}, err
+	}
+	result := MembershipResult{Changes: append(p.changes[StepStarterPacks], p.changes[StepLists]...)}
+	return result.Succeeded(bskyHandleOwner), nil
+}
+
+func (p *verificationPipeline) runStep(step string) error {
+	switch step {
+	case StepStore:
+		store, err := kv.OpenStore("default")
+		if err != nil {
+			return err
+		}
+		previousValue, err := store.Get(p.naming.Key + "-" + p.pending.VerificationId)
+		store.Close()
+		if err == nil {
+			p.pending.PreviousEntry = string(previousValue)
+		}
+		return Store(p.naming, p.pending.VerificationId, p.pending.Did, p.pending.Handle, p.pending.ProofMode, p.pending.LevelLabels)
+
+	case StepLists, StepStarterPacks:
+		return p.addMemberships(step)
+
+	case StepFollow:
+		_, err := Follow(p.pending.Did, p.accessJwt, p.endpoint)
+		if err != nil {
+			// only print the error as this is not technically blocking the application usecase
+			LogWarn("Error following user", Field("did", p.pending.Did), Field("error", err))
+		}
+		return nil
+
+	case StepLabel:
+		exp := time.Time{}
+		if m, err := GetModuleSpecifics(p.pending.ModuleKey); err == nil {
+			exp = m.LabelExpiry()
+		}
+		for _, label := range append([]string{p.pending.Label}, p.pending.LevelLabels...) {
+			err := SetLabelWithExpiry(label, p.pending.Did, exp, p.accessJwt, p.endpoint)
+			if err != nil {
+				return fmt.Errorf("Error setting label " + label + " on user: " + err.Error())
+			}
+		}
+		// the level changed since the last verification, e.g. a new award category
+		for _, label := range p.previousLevelLabels() {
+			err := RemoveLabel(label, p.pending.Did, p.accessJwt, p.endpoint)
+			if err != nil {
+				return fmt.Errorf("Error removing label " + label + " from user: " + err.Error())
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown verification step " + step)
+}
+
+func (p *verificationPipeline) addMemberships(step string) error {
+	timestamp := time.Now().Format("2006-01-02T15:04:05.000Z")
+	titles := []TitleAndDescription{{Title: p.naming.Title, Description: p.naming.Description}}
+	for first, secondArray := range p.naming.FirstAndSecondLevel {
+		titles = append(titles, first)
+		titles = append(titles, secondArray...)
+	}
+
+	changes := []MembershipChange{}
+	if step == StepLists {
+		lists, err := GetLists(p.accessJwt, p.endpoint)
+		if err != nil {
+			return err
+		}
+		for _, title := range titles {
+			changes = append(changes, PlanListMembership(p.pending.Did, title.Title, lists, timestamp, p.accessJwt, p.endpoint))
+		}
+	} else {
+		starterPacks, err := GetStarterPacks(p.accessJwt, p.endpoint)
+		if err != nil {
+			return err
+		}
+		for _, title := range titles {
+			changes = append(changes, PlanStarterPackMembership(p.pending.Did, title.Title, title.Description, starterPacks, timestamp, p.accessJwt, p.endpoint))
+		}
+	}
+
+	result := ApplyMembershipChanges(changes, p.accessJwt, p.endpoint)
+	p.changes[step] = result.Changes
+	return result.Err()
+}
+
+// rollback undoes the completed steps in reverse order and the part of the failed step that was applied. It stops at
+// the first compensation that fails and stores the state as pending
+func (p *verificationPipeline) rollback(failedStep string, stepErr error) error {
+	p.pending.FailedStep = failedStep
+	p.pending.Error = stepErr.Error()
+
+	steps := append([]string{failedStep}, reverse(p.pending.CompletedSteps)...)
+	for _, step := range steps {
+		LogInfo("Rolling back verification step", Field("step", step), Field("did", p.pending.Did))
+		undone, err := p.compensate(step)
+		if err != nil {
+			LogError("Rolling back verification step failed", Field("step", step), Field("did", p.pending.Did), Field("error", err))
+			if undone {
+				p.removeCompleted(step)
+			}
+			key, storeErr := p.storePending()
+			if storeErr != nil {
+				LogError("Error storing pending verification", Field("did", p.pending.Did), Field("error", storeErr))
+			}
+			return &PipelineError{Step: failedStep, Err: stepErr, PendingKey: key}
+		}
+		p.removeCompleted(step)
+	}
+
+	err := p.deletePending()
+	if err != nil {
+		LogError("Error deleting pending verification", Field("did", p.pending.Did), Field("error", err))
+	}
+	return &PipelineError{Step: failedStep, Err: stepErr, RolledBack: true}
+}
+
+// compensate undoes a step. The bool reports if anything was undone, even if an error occurred
+func (p *verificationPipeline) compensate(step string) (bool, error) {
+	switch step {
+	case StepStore:
+		store, err := kv.OpenStore("default")
+		if err != nil {
+			return false, err
+		}
+		defer store.Close()
+		key := p.naming.Key + "-" + p.pending.VerificationId
+		if p.pending.PreviousEntry != "" {
+			return true, store.Set(key, []byte(p.pending.PreviousEntry))
+		}
+		return true, store.Delete(key)
+
+	case StepLists, StepStarterPacks:
+		// the step completed in an earlier run, so the user is removed from all containers with the titles
+		if _, ok := p.changes[step]; !ok {
+			return true, p.removeFromAllContainers(step)
+		}
+		undone := false
+		for i, change := range p.changes[step] {
+			if change.Status != MembershipAdded {
+				continue
+			}
+			_, err := CheckOrDeleteUserOnList(change.ListURI, p.pending.Did, true, p.accessJwt, p.endpoint)
+			if err != nil {
+				return undone, err
+			}
+			p.changes[step][i].Status = MembershipPending
+			undone = true
+		}
+		return undone, nil
+
+	case StepLabel:
+		// on a new verification, labels that were already set are removed again. On a re-verification the user keeps them
+		if p.pending.PreviousEntry != "" {
+			return false, nil
+		}
+		undone := false
+		for _, label := range append([]string{p.pending.Label}, p.pending.LevelLabels...) {
+			err := RemoveLabel(label, p.pending.Did, p.accessJwt, p.endpoint)
+			if err != nil {
+				return undone, err
+			}
+			undone = true
+		}
+		return undone, nil
+	}
+
+	// following is harmless, so there is nothing to undo
+	return false, nil
+}
+
+// previousLevelLabels returns the level labels of the previous verification of the same account that don't apply anymore
+func (p *verificationPipeline) previousLevelLabels() []string {
+	if p.pending.PreviousEntry == "" {
+		return []string{}
+	}
+	previous := ParseStoreEntry([]byte(p.pending.PreviousEntry))
+	if previous.Did != p.pending.Did {
+		return []string{}
+	}
+	m, err := GetModuleSpecifics(p.pending.ModuleKey)
+	if err != nil {
+		LogError("Error getting module specifics", Field("module", p.pending.ModuleKey), Field("error", err))
+		return staleLabels(previous.LevelLabels, p.pending.LevelLabels)
+	}
+	return staleLabels(m.StoredLevelLabels(previous), p.pending.LevelLabels)
+}
+
+func (p *verificationPipeline) removeFromAllContainers(step string) error {
+	titles := map[string]bool{p.naming.Title: true}
+	for first, secondArray := range p.naming.FirstAndSecondLevel {
+		titles[first.Title] = true
+		for _, second := range secondArray {
+			titles[second.Title] = true
+		}
+	}
+
+	listUris := []string{}
+	if step == StepLists {
+		lists, err := GetLists(p.accessJwt, p.endpoint)
+		if err != nil {
+			return err
+		}
+		for _, list := range lists {
+			if titles[list.Name] {
+				listUris = append(listUris, list.URI)
+			}
+		}
+	} else {
+		starterPacks, err := GetStarterPacks(p.accessJwt, p.endpoint)
+		if err != nil {
+			return err
+		}
+		for _, starterPack := range starterPacks {
+			if titles[starterPack.Record.Name] {
+				listUris = append(listUris, starterPack.Record.List)
+			}
+		}
+	}
+
+	for _, listUri := range listUris {
+		_, err := CheckOrDeleteUserOnList(listUri, p.pending.Did, true, p.accessJwt, p.endpoint)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *verificationPipeline) removeCompleted(step string) {
+	completedSteps := []string{}
+	for _, completedStep := range p.pending.CompletedSteps {
+		if completedStep != step {
+			completedSteps = append(completedSteps, completedStep)
+		}
+	}
+	p.pending.CompletedSteps = completedSteps
+}
+
+func (p *verificationPipeline) storePending() (string, error) {
+	key := pendingKey(p.pending.ModuleKey, p.pending.VerificationId)
+	p.pending.UpdatedAt = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	value, err := json.Marshal(p.pending)
+	if err != nil {
+		return key, err
+	}
+
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return key, err
+	}
+	defer store.Close()
+	return key, store.Set(key, value)
+}
+
+func (p *verificationPipeline) deletePending() error {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	key := pendingKey(p.pending.ModuleKey, p.pending.VerificationId)
+	exists, err := store.Exists(key)
+	if err != nil || !exists {
+		return err
+	}
+	return store.Delete(key)
+}
+
+// PendingRetryResult lists the keys of the pending verifications by their outcome
+type PendingRetryResult struct {
+	Completed    []string `json:"completed"`
+	RolledBack   []string `json:"rolledBack"`
+	StillPending []string `json:"stillPending"`
+}
+
+// RetryPendingVerifications runs the remaining steps of all pending verifications. If a step fails again,
+// the verification is rolled back or stays pending
+func RetryPendingVerifications(accessJwt string, endpoint string) (PendingRetryResult, error) {
+	result := PendingRetryResult{Completed: []string{}, RolledBack: []string{}, StillPending: []string{}}
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return result, err
+	}
+	keys, err := store.GetKeys()
+	if err != nil {
+		store.Close()
+		return result, err
+	}
+
+	pendingEntries := map[string]PendingVerification{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, pendingKeyPrefix) {
+			continue
+		}
+		value, err := store.Get(key)
+		if err != nil {
+			store.Close()
+			return result, err
+		}
+		var pending PendingVerification
+		err = json.Unmarshal(value, &pending)
+		if err != nil {
+			LogError("Error decoding pending verification", Field("key", key), Field("error", err))
+			continue
+		}
+		pendingEntries[key] = pending
+	}
+	store.Close()
+
+	for key, pending := range pendingEntries {
+		LogInfo("Retrying pending verification", Field("key", key), Field("step", pending.FailedStep))
+		pending.Attempts++
+		p := newVerificationPipeline(pending, accessJwt, endpoint)
+		_, err := p.run()
+		if err != nil {
+			LogError("Retrying pending verification failed", Field("key", key), Field("error", err))
+			var pipelineError *PipelineError
+			if errors.As(err, &pipelineError) && pipelineError.RolledBack {
+				result.RolledBack = append(result.RolledBack, key)
+			} else {
+				result.StillPending = append(result.StillPending, key)
+			}
+			continue
+		}
+		err = p.deletePending()
+		if err != nil {
+			return result, err
+		}
+		result.Completed = append(result.Completed, key)
+	}
+	return result, nil
+}
+
+func reverse(steps []string) []string {
+	reversed := make([]string, len(steps))
+	for i, step := range steps {
+		reversed[len(steps)-1-i] = step
+	}
+	return reversed
+}
@@ -0,0 +1,78 @@
+package shared
+
+import (
+	"regexp"
+	"strings"
+)
+
+// BlueskyAppViewHosts are the web apps that show a Bluesky profile at /profile/<handle or DID>
+var BlueskyAppViewHosts = []string{
+	"bsky.app",
+	"staging.bsky.app",
+	"main.bsky.dev",
+	"deer.social",
+	"blacksky.community",
+	"zeppelin.social",
+}
+
+// matches a profile URL of one of the AppViews, the first group is the handle or DID
+var profileUrlPattern = compileProfileUrlPattern()
+
+func compileProfileUrlPattern() *regexp.Regexp {
+	hosts := make([]string, len(BlueskyAppViewHosts))
+	for i, host := range BlueskyAppViewHosts {
+		hosts[i] = regexp.QuoteMeta(host)
+	}
+	return regexp.MustCompile(`(?i)(?:https?://)?(?:www\.)?(?:` + strings.Join(hosts, "|") + `)/profile/@?([a-zA-Z0-9._:%-]+)`)
+}
+
+// MatchesBlueskyProfile checks if a value like a link in a social network field points to the Bluesky account.
+// It accepts profile URLs of all known AppViews with or without scheme, www., trailing slash, query string and
+// fragment, as well as the plain handle, @handle or DID. The comparison ignores case, bskyDid is optional
+func MatchesBlueskyProfile(value string, bskyHandle string, bskyDid string) bool {
+	candidate := strings.TrimSpace(value)
+	if candidate == "" {
+		return false
+	}
+
+	if loc := profileUrlPattern.FindStringSubmatchIndex(candidate); loc != nil && loc[0] == 0 {
+		// only the profile itself counts, not e.g. a post or list of it
+		rest := strings.TrimPrefix(candidate[loc[1]:], "/")
+		if rest == "" || strings.HasPrefix(rest, "?") || strings.HasPrefix(rest, "#") {
+			return isSameAccount(candidate[loc[2]:loc[3]], bskyHandle, bskyDid)
+		}
+		return false
+	}
+
+	if strings.ContainsAny(candidate, "/ ") {
+		return false
+	}
+	return isSameAccount(strings.TrimPrefix(candidate, "@"), bskyHandle, bskyDid)
+}
+
+// ContainsBlueskyProfile checks if a text like an HTML attribute or a bio contains a profile URL of the Bluesky account
+func ContainsBlueskyProfile(text string, bskyHandle string, bskyDid string) bool {
+	for _, loc := range profileUrlPattern.FindAllStringSubmatchIndex(text, -1) {
+		// the AppView host must not be the end of another host or a path, e.g. notbsky.app/profile/...
+		if loc[0] > 0 && isUrlChar(text[loc[0]-1]) {
+			continue
+		}
+		rest := text[loc[1]:]
+		if len(rest) > 1 && rest[0] == '/' && !strings.ContainsAny(rest[1:2], " \t\n\"'<>?#") {
+			continue
+		}
+		// a dot after the handle usually ends the sentence
+		if isSameAccount(strings.TrimRight(text[loc[2]:loc[3]], "."), bskyHandle, bskyDid) {
+			return true
+		}
+	}
+	return false
+}
+
+func isUrlChar(char byte) bool {
+	return char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || strings.IndexByte(".-_/@:%", char) >= 0
+}
+
+func isSameAccount(actor string, bskyHandle string, bskyDid string) bool {
+	return (bskyHandle != "" && strings.EqualFold(actor, bskyHandle)) || (bskyDid != "" && strings.EqualFold(actor, bskyDid))
+}
@@ -0,0 +1,83 @@
+package shared
+
+import "testing"
+
+func TestMatchesBlueskyProfile(t *testing.T) {
+	const handle = "alice.bsky.social"
+	const did = "did:plc:abc123"
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"https://bsky.app/profile/alice.bsky.social", true},
+		{"http://bsky.app/profile/alice.bsky.social", true},
+		{"bsky.app/profile/alice.bsky.social", true},
+		{"https://www.bsky.app/profile/alice.bsky.social", true},
+		{"https://bsky.app/profile/alice.bsky.social/", true},
+		{"https://bsky.app/profile/alice.bsky.social?ref=mvp", true},
+		{"https://bsky.app/profile/alice.bsky.social#top", true},
+		{"https://bsky.app/profile/@alice.bsky.social", true},
+		{"  https://bsky.app/profile/alice.bsky.social  ", true},
+		{"HTTPS://BSKY.APP/profile/Alice.Bsky.Social", true},
+		{"https://bsky.app/profile/did:plc:abc123", true},
+		{"https://deer.social/profile/alice.bsky.social", true},
+		{"https://staging.bsky.app/profile/alice.bsky.social", true},
+		{"https://blacksky.community/profile/did:plc:abc123", true},
+		{"alice.bsky.social", true},
+		{"@alice.bsky.social", true},
+		{"@Alice.Bsky.Social", true},
+		{"did:plc:abc123", true},
+		{"", false},
+		{"https://bsky.app/profile/bob.bsky.social", false},
+		{"https://bsky.app/profile/alice.bsky.social.evil.com", false},
+		{"https://bsky.app/profile/alice.bsky.social/post/3k2abc", false},
+		{"https://bsky.app/profile/alice.bsky.social/lists/3k2abc", false},
+		{"https://notbsky.app/profile/alice.bsky.social", false},
+		{"https://evil.com/bsky.app/profile/alice.bsky.social", false},
+		{"https://example.com/alice.bsky.social", false},
+		{"alice bsky.social", false},
+	}
+	for _, test := range tests {
+		if matches := MatchesBlueskyProfile(test.value, handle, did); matches != test.expected {
+			t.Errorf("MatchesBlueskyProfile(%q) = %v, want %v", test.value, matches, test.expected)
+		}
+	}
+}
+
+func TestMatchesBlueskyProfileWithoutDid(t *testing.T) {
+	if MatchesBlueskyProfile("did:plc:abc123", "alice.bsky.social", "") {
+		t.Error("a DID must not match without bskyDid")
+	}
+	if MatchesBlueskyProfile("", "", "") {
+		t.Error("an empty value must not match an empty account")
+	}
+}
+
+func TestContainsBlueskyProfile(t *testing.T) {
+	const handle = "alice.bsky.social"
+	const did = "did:plc:abc123"
+	tests := []struct {
+		text     string
+		expected bool
+	}{
+		{`<a href="https://bsky.app/profile/alice.bsky.social">Bluesky</a>`, true},
+		{"Find me at bsky.app/profile/alice.bsky.social.", true},
+		{"Find me at https://www.bsky.app/profile/@Alice.Bsky.Social!", true},
+		{"(https://bsky.app/profile/did:plc:abc123)", true},
+		{"https://zeppelin.social/profile/alice.bsky.social/", true},
+		{"https://bsky.app/profile/alice.bsky.social?ref=bio", true},
+		{"https://bsky.app/profile/alice.bsky.social#posts", true},
+		{"first https://bsky.app/profile/bob.bsky.social then https://bsky.app/profile/alice.bsky.social", true},
+		{"I am alice.bsky.social", false},
+		{"https://bsky.app/profile/alice.bsky.social/post/3k2abc", false},
+		{"https://notbsky.app/profile/alice.bsky.social", false},
+		{"notbsky.app/profile/alice.bsky.social", false},
+		{"https://evil.com/bsky.app/profile/alice.bsky.social", false},
+		{"https://bsky.app/profile/bob.bsky.social", false},
+	}
+	for _, test := range tests {
+		if contains := ContainsBlueskyProfile(test.text, handle, did); contains != test.expected {
+			t.Errorf("ContainsBlueskyProfile(%q) = %v, want %v", test.text, contains, test.expected)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
+)
+
+// ModuleInfo is the public description of a registered verification source
+type ModuleInfo struct {
+	Key             string              `json:"key"`
+	Name            string              `json:"name"`
+	NameShortened   string              `json:"nameShortened"`
+	Label           string              `json:"label"`
+	ExplanationText string              `json:"explanationText"`
+	ValidationPath  string              `json:"validationPath"`
+	Levels          map[string][]string `json:"levels"`
+	// the account can be verified with a token on Bluesky instead of a link at the source
+	ChallengeSupported bool `json:"challengeSupported"`
+}
+
+var registeredModules = map[string]ModuleSpecifics{}
+
+func init() {
+	RegisterModule(getMvpModuleSpecifics())
+	RegisterModule(getRdModuleSpecifics())
+	RegisterModule(getGhStarModuleSpecifics())
+	RegisterModule(getJavaChampsModuleSpecifics())
+	RegisterModule(getAfmModuleSpecifics())
+	// without configured events, no speaker can be verified
+	loadSessionizeEvents()
+	if len(SessionizeEvents) > 0 {
+		RegisterModule(getSessionizeModuleSpecifics())
+	}
+}
+
+// RegisterModule adds a verification source to the registry or replaces an already registered one with the same key
+func RegisterModule(m ModuleSpecifics) {
+	registeredModules[m.ModuleKey] = m
+}
+
+// GetModuleSpecifics returns the ModuleSpecifics for a given moduleKey
+func GetModuleSpecifics(moduleKey string) (ModuleSpecifics, error) {
+	m, ok := registeredModules[moduleKey]
+	if !ok {
+		return ModuleSpecifics{}, fmt.Errorf("unknown module key: %s", moduleKey)
+	}
+	return m, nil
+}
+
+// GetAllModuleSpecifics returns all registered verification sources, sorted by name
+func GetAllModuleSpecifics() []ModuleSpecifics {
+	modules := make([]ModuleSpecifics, 0, len(registeredModules))
+	for _, m := range registeredModules {
+		modules = append(modules, m)
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].ModuleName < modules[j].ModuleName
+	})
+	return modules
+}
+
+// ValidationPath is the route of the component that verifies accounts for this module
+func (m ModuleSpecifics) ValidationPath() string {
+	return "/validate-" + m.ModuleKey
+}
+
+// ServeModule attaches the verification and naming functions to a registered module and
+// handles the requests of the component with it. If no naming function is given, the
+// levels of the module are used as they are.
+func ServeModule(moduleKey string, verificationFunc func(verificationId string, bskyHandle string, bskyDid string) (bool, error), namingFunc func(m ModuleSpecifics, verificationId string) (Naming, error)) {
+	ServeModuleWithExistence(moduleKey, verificationFunc, namingFunc, nil)
+}
+
+// ServeModuleWithExistence works like ServeModule and additionally attaches the function that checks if
+// a profile exists at the verification source, which is needed for the challenge proof mode
+func ServeModuleWithExistence(moduleKey string, verificationFunc func(verificationId string, bskyHandle string, bskyDid string) (bool, error), namingFunc func(m ModuleSpecifics, verificationId string) (Naming, error), existenceFunc func(verificationId string) (bool, error)) {
+	m, err := GetModuleSpecifics(moduleKey)
+	if err != nil {
+		// e.g. the Sessionize module without configured events
+		spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Module "+moduleKey+" is not available: "+err.Error(), http.StatusNotFound)
+		})
+		return
+	}
+
+	m.VerificationFunc = verificationFunc
+	m.ExistenceFunc = existenceFunc
+	m.NamingFunc = namingFunc
+	if m.NamingFunc == nil {
+		m.NamingFunc = func(m ModuleSpecifics, _ string) (Naming, error) {
+			return SetupNamingStructure(m)
+		}
+	}
+	RegisterModule(m)
+
+	spinhttp.Handle(m.Handle)
+}
+
+func RespondWithAllModules(w http.ResponseWriter) error {
+	modules := []ModuleInfo{}
+	for _, m := range GetAllModuleSpecifics() {
+		modules = append(modules, ModuleInfo{
+			Key:                m.ModuleKey,
+			Name:               m.ModuleName,
+			NameShortened:      m.ModuleNameShortened,
+			Label:              m.ModuleLabel,
+			ExplanationText:    m.ExplanationText,
+			ValidationPath:     m.ValidationPath(),
+			Levels:             m.FirstAndSecondLevel,
+			ChallengeSupported: m.ChallengeSupported,
+		})
+	}
+
+	jsonResult, err := json.Marshal(modules)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintln(w, string(jsonResult))
+	return nil
+}
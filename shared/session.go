@@ -0,0 +1,107 @@
+package shared
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fermyon/spin/sdk/go/v2/kv"
+)
+
+// Keys of the session in the default store
+const (
+	accessJwtKey  = "accessJwt"
+	refreshJwtKey = "refreshJwt"
+	endpointKey   = "endpoint"
+)
+
+// a token that expires within the margin is refreshed right away so it doesn't expire during a request
+const jwtExpiryMargin = 2 * time.Minute
+
+// JwtExpiry reads the exp claim of a JWT without verifying the signature. It returns false if the token
+// has no readable expiry
+func JwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
+
+// jwtIsCurrent checks if the token is still valid after the margin. The second value is false if the token has no
+// readable expiry
+func jwtIsCurrent(token string, now time.Time) (bool, bool) {
+	expiresAt, ok := JwtExpiry(token)
+	if !ok {
+		return false, false
+	}
+	return now.Add(jwtExpiryMargin).Before(expiresAt), true
+}
+
+// RefreshSession gets a new access token with the refresh token and stores the new session
+func RefreshSession(store *kv.Store, refreshJwt string, endpoint string) (string, error) {
+	if expiresAt, ok := JwtExpiry(refreshJwt); ok && time.Now().After(expiresAt) {
+		return "", fmt.Errorf("refreshJwt is expired")
+	}
+
+	LogInfo("Refreshing the Bluesky session")
+	client := NewXrpcClient(endpoint, refreshJwt)
+	client.NoLog = true
+
+	var response AuthResponse
+	err := client.Procedure("com.atproto.server.refreshSession", nil, &response)
+	if err != nil {
+		return "", err
+	}
+	if response.AccessJwt == "" {
+		return "", fmt.Errorf("No accessJwt in the refreshSession response")
+	}
+
+	// the response only contains the DID document if the PDS sends it along
+	if pdsEndpoint, err := response.DidDoc.PdsEndpoint(); err == nil && PdsUrl() == "" {
+		endpoint = pdsEndpoint
+	}
+	storeSession(store, response, endpoint)
+	LogInfo("Refreshed the session successfully")
+	return response.AccessJwt, nil
+}
+
+func storeSession(store *kv.Store, response AuthResponse, endpoint string) {
+	LogDebug("Storing accessJwt, refreshJwt and endpoint")
+	err := store.Set(accessJwtKey, []byte(response.AccessJwt))
+	if err != nil {
+		LogError("Error storing accessJwt", Field("error", err))
+	}
+	err = store.Set(refreshJwtKey, []byte(response.RefreshJwt))
+	if err != nil {
+		LogError("Error storing refreshJwt", Field("error", err))
+	}
+	err = store.Set(endpointKey, []byte(endpoint))
+	if err != nil {
+		LogError("Error storing endpoint", Field("error", err))
+	}
+}
+
+func getFromStore(store *kv.Store, key string) (string, error) {
+	value, err := store.Get(key)
+	if err != nil {
+		if err.Error() == "no such key" {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(value), nil
+}
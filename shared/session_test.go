@@ -0,0 +1,61 @@
+package shared
+
+import (
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testToken(payload string) string {
+	return "eyJhbGciOiJFUzI1NksifQ." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2ln"
+}
+
+func TestJwtExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		expected time.Time
+		ok       bool
+	}{
+		{"valid exp", testToken(`{"sub":"did:plc:abc","exp":1700000000}`), time.Unix(1700000000, 0), true},
+		{"padded payload", "eyJhbGciOiJFUzI1NksifQ." + base64.URLEncoding.EncodeToString([]byte(`{"exp":1700000000}`)) + ".c2ln", time.Unix(1700000000, 0), true},
+		{"no exp", testToken(`{"sub":"did:plc:abc"}`), time.Time{}, false},
+		{"exp is not a number", testToken(`{"exp":"tomorrow"}`), time.Time{}, false},
+		{"payload is not JSON", testToken(`not json`), time.Time{}, false},
+		{"payload is not base64", "eyJhbGciOiJFUzI1NksifQ.%%%.c2ln", time.Time{}, false},
+		{"two parts", "eyJhbGciOiJFUzI1NksifQ.eyJleHAiOjE3MDAwMDAwMDB9", time.Time{}, false},
+		{"empty", "", time.Time{}, false},
+	}
+	for _, test := range tests {
+		expiresAt, ok := JwtExpiry(test.token)
+		if ok != test.ok || !expiresAt.Equal(test.expected) {
+			t.Errorf("%s: JwtExpiry() = %v, %v, want %v, %v", test.name, expiresAt, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestJwtIsCurrent(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	expiringAt := func(expiresAt time.Time) string {
+		return testToken(`{"exp":` + strconv.FormatInt(expiresAt.Unix(), 10) + `}`)
+	}
+	tests := []struct {
+		name     string
+		token    string
+		current  bool
+		readable bool
+	}{
+		{"expires after the margin", expiringAt(now.Add(time.Hour)), true, true},
+		{"expires inside the margin", expiringAt(now.Add(jwtExpiryMargin - time.Second)), false, true},
+		{"expires exactly at the margin", expiringAt(now.Add(jwtExpiryMargin)), false, true},
+		{"expired", expiringAt(now.Add(-time.Minute)), false, true},
+		{"malformed", "not-a-jwt", false, false},
+	}
+	for _, test := range tests {
+		current, readable := jwtIsCurrent(test.token, now)
+		if current != test.current || readable != test.readable {
+			t.Errorf("%s: jwtIsCurrent() = %v, %v, want %v, %v", test.name, current, readable, test.current, test.readable)
+		}
+	}
+}
@@ -3,74 +3,136 @@ package shared
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 type Link struct {
-    Title    string `json:"title"`
-    URL      string `json:"url"`
-    LinkType string `json:"linkType"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	LinkType string `json:"linkType"`
 }
 
 type Session struct {
-    ID   int    `json:"id"`
-    Name string `json:"name"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
 }
 
 type Profile struct {
-    ID             string    `json:"id"`
-    FirstName      string    `json:"firstName"`
-    LastName       string    `json:"lastName"`
-    FullName       string    `json:"fullName"`
-    Bio            string    `json:"bio"`
-    TagLine        string    `json:"tagLine"`
-    ProfilePicture string    `json:"profilePicture"`
-    Sessions       []Session `json:"sessions"`
-    IsTopSpeaker   bool      `json:"isTopSpeaker"`
-    Links          []Link    `json:"links"`
-    QuestionAnswers []interface{} `json:"questionAnswers"`
-    Categories     []interface{} `json:"categories"`
+	ID              string        `json:"id"`
+	FirstName       string        `json:"firstName"`
+	LastName        string        `json:"lastName"`
+	FullName        string        `json:"fullName"`
+	Bio             string        `json:"bio"`
+	TagLine         string        `json:"tagLine"`
+	ProfilePicture  string        `json:"profilePicture"`
+	Sessions        []Session     `json:"sessions"`
+	IsTopSpeaker    bool          `json:"isTopSpeaker"`
+	Links           []Link        `json:"links"`
+	QuestionAnswers []interface{} `json:"questionAnswers"`
+	Categories      []interface{} `json:"categories"`
 }
 
-func SessionizeVerification(verificationId string, bskyHandle string, sessionizeId string) (bool, error) {
-	fmt.Println("Verifying Sessionize speaker " + verificationId + " with Bluesky handle " + bskyHandle + " at event " + sessionizeId)
-	profile, err := getSessionizeSpeakerProfileAtEvent(verificationId, sessionizeId)
+// SessionizeBlueskyLinkType is the link type of the dedicated Bluesky field of Sessionize profiles
+const SessionizeBlueskyLinkType = "Bluesky"
+
+// Reasons why a Sessionize verification failed
+const (
+	SessionizeEventUnavailable = "event-unavailable"
+	SessionizeSpeakerNotFound  = "speaker-not-found"
+	SessionizeLinkMissing      = "bluesky-link-missing"
+)
+
+// SessionizeVerificationError is returned by the Sessionize verification with the reason of the failure
+type SessionizeVerificationError struct {
+	Reason         string `json:"reason"`
+	SessionizeId   string `json:"sessionizeId"`
+	Event          string `json:"event,omitempty"`
+	VerificationId string `json:"verificationId"`
+	BskyHandle     string `json:"bskyHandle,omitempty"`
+	Details        string `json:"details,omitempty"`
+}
+
+func (e *SessionizeVerificationError) Error() string {
+	switch e.Reason {
+	case SessionizeEventUnavailable:
+		return "Could not get the Sessionize speaker list of event " + e.SessionizeId + ": " + e.Details
+	case SessionizeSpeakerNotFound:
+		return "Speaker " + e.VerificationId + " not found in Sessionize speaker list of event " + e.SessionizeId
+	case SessionizeLinkMissing:
+		return "Speaker " + e.VerificationId + " does not have the Bluesky link https://bsky.app/profile/" + e.BskyHandle + " on their Sessionize profile for event " + e.SessionizeId
+	}
+	return "Sessionize verification failed for speaker " + e.VerificationId + ": " + e.Reason
+}
+
+// SessionizeSpeakerError is returned when a speaker could not be verified at any event, with the reason per event
+type SessionizeSpeakerError struct {
+	Message string                         `json:"message"`
+	Events  []*SessionizeVerificationError `json:"events"`
+}
+
+func (e *SessionizeSpeakerError) Error() string {
+	messages := []string{}
+	for _, event := range e.Events {
+		messages = append(messages, event.Event+": "+event.Error())
+	}
+	return e.Message + ": " + strings.Join(messages, "; ")
+}
+
+// SessionizeVerification checks if the speaker links to the Bluesky profile on Sessionize. The verificationId
+// can be the Sessionize speaker ID or the full name, bskyDid is optional and allows DID-based profile links
+func SessionizeVerification(verificationId string, bskyHandle string, bskyDid string, sessionizeId string) (bool, error) {
+	LogInfo("Verifying Sessionize speaker", Field("verificationId", verificationId), Field("handle", bskyHandle), Field("event", sessionizeId))
+	profile, err := GetSessionizeSpeakerProfileAtEvent(verificationId, sessionizeId)
 	if err != nil {
 		return false, err
 	}
-	return checkIfSpeakerHasBlueskyLink(profile, bskyHandle)
+	if !checkIfSpeakerHasBlueskyLink(profile, bskyHandle, bskyDid) {
+		return false, &SessionizeVerificationError{Reason: SessionizeLinkMissing, SessionizeId: sessionizeId, VerificationId: verificationId, BskyHandle: bskyHandle}
+	}
+	return true, nil
 }
 
-func getSessionizeSpeakerProfileAtEvent(verificationId string, sessionizeId string) (Profile, error) {
+func GetSessionizeSpeakerProfileAtEvent(verificationId string, sessionizeId string) (Profile, error) {
 	url := fmt.Sprintf("https://sessionize.com/api/v2/%s/view/Speakers", sessionizeId)
 
 	resp, err := SendGet(url, "")
 	if err != nil {
-		fmt.Println("Error fetching the URL: " + err.Error())
-		return Profile{}, fmt.Errorf("Error fetching the Sessionize speaker list: "+err.Error())
+		LogError("Error fetching the URL", Field("url", url), Field("error", err))
+		return Profile{}, &SessionizeVerificationError{Reason: SessionizeEventUnavailable, SessionizeId: sessionizeId, VerificationId: verificationId, Details: err.Error()}
 	}
 	defer resp.Body.Close()
 
 	var profiles []Profile
 	err = json.NewDecoder(resp.Body).Decode(&profiles)
 	if err != nil {
-		fmt.Println("Error decoding Sessionize speaker list JSON: " + err.Error())
-		return Profile{}, fmt.Errorf("Error decoding Sessionize speaker list JSON: "+err.Error())
+		LogError("Error decoding Sessionize speaker list JSON", Field("event", sessionizeId), Field("error", err))
+		return Profile{}, &SessionizeVerificationError{Reason: SessionizeEventUnavailable, SessionizeId: sessionizeId, VerificationId: verificationId, Details: err.Error()}
 	}
 
+	// the speaker ID is stable, the full name is only used if no speaker has the ID
+	for _, profile := range profiles {
+		if strings.EqualFold(profile.ID, strings.TrimSpace(verificationId)) {
+			return profile, nil
+		}
+	}
 	for _, profile := range profiles {
-		if profile.FullName == verificationId {
+		if profile.FullName == strings.TrimSpace(verificationId) {
 			return profile, nil
 		}
 	}
 
-	return Profile{}, fmt.Errorf("Speaker " + verificationId + " not found in Sessionize speaker list of the event")
+	return Profile{}, &SessionizeVerificationError{Reason: SessionizeSpeakerNotFound, SessionizeId: sessionizeId, VerificationId: verificationId}
 }
 
-func checkIfSpeakerHasBlueskyLink(profile Profile, bskyHandle string) (bool, error) {
+func checkIfSpeakerHasBlueskyLink(profile Profile, bskyHandle string, bskyDid string) bool {
 	for _, link := range profile.Links {
-		if link.LinkType == "Other" && link.URL == "https://bsky.app/profile/"+bskyHandle {
-			return true, nil
+		// the dedicated Bluesky link type sometimes only contains the handle, other link types need a profile URL
+		if !strings.EqualFold(link.LinkType, SessionizeBlueskyLinkType) && !strings.Contains(link.URL, "/") {
+			continue
+		}
+		if MatchesBlueskyProfile(link.URL, bskyHandle, bskyDid) {
+			return true
 		}
 	}
-	return false, fmt.Errorf("Speaker does not have the Bluesky link https://bsky.app/profile/" + bskyHandle + " on their Sessionize profile")	
-}
\ No newline at end of file
+	return false
+}
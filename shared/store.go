@@ -0,0 +1,290 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fermyon/spin/sdk/go/v2/kv"
+	"github.com/fermyon/spin/sdk/go/v2/variables"
+)
+
+// AppVersion is written to every stored verification. The deployment sets the Spin variable app_version from the
+// release tag, local builds use dev
+func AppVersion() string {
+	version, err := variables.Get("app_version")
+	if err != nil || strings.TrimSpace(version) == "" {
+		return "dev"
+	}
+	return strings.TrimPrefix(strings.TrimSpace(version), "v")
+}
+
+// StoreEntry is the value persisted for a verification. The DID is the identity,
+// the handle is only kept for display purposes as it can change at any time.
+type StoreEntry struct {
+	Did                 string              `json:"did"`
+	Handle              string              `json:"handle"`
+	ModuleKey           string              `json:"moduleKey,omitempty"`
+	VerificationId      string              `json:"verificationId,omitempty"`
+	FirstVerifiedAt     string              `json:"firstVerifiedAt,omitempty"`
+	LastValidatedAt     string              `json:"lastValidatedAt,omitempty"`
+	FirstAndSecondLevel map[string][]string `json:"firstAndSecondLevel,omitempty"`
+	AppVersion          string              `json:"appVersion,omitempty"`
+	ProofMode           string              `json:"proofMode,omitempty"`
+	// labels of the first levels that were applied next to the module label
+	LevelLabels []string `json:"levelLabels,omitempty"`
+}
+
+// IsVerificationKey returns false for the keys in the default store that don't hold a verification
+func IsVerificationKey(key string) bool {
+	return key != "" && key != accessJwtKey && key != refreshJwtKey && key != endpointKey && !strings.HasPrefix(key, challengeKeyPrefix) && !strings.HasPrefix(key, pendingKeyPrefix) && !strings.HasPrefix(key, approvalKeyPrefix) && !strings.HasPrefix(key, listIndexKeyPrefix) && strings.Contains(key, "-")
+}
+
+// ParseStoreEntry decodes a value from the k/v store. Entries written before the
+// switch to DIDs only contain the plain handle and are returned without a DID.
+func ParseStoreEntry(value []byte) StoreEntry {
+	var entry StoreEntry
+	if strings.HasPrefix(strings.TrimSpace(string(value)), "{") && json.Unmarshal(value, &entry) == nil {
+		return entry
+	}
+	return StoreEntry{Handle: string(value)}
+}
+
+// ParseStoreEntryWithKey decodes a value from the k/v store and fills the module key and
+// verification ID from the key for entries that don't contain them yet.
+func ParseStoreEntryWithKey(key string, value []byte) StoreEntry {
+	entry := ParseStoreEntry(value)
+	parts := strings.SplitN(key, "-", 2)
+	if entry.ModuleKey == "" {
+		entry.ModuleKey = parts[0]
+	}
+	if entry.VerificationId == "" && len(parts) == 2 {
+		entry.VerificationId = parts[1]
+	}
+	return entry
+}
+
+// BelongsTo checks if the entry is for the account with the given DID. Legacy entries
+// without a DID fall back to comparing the handle.
+func (e StoreEntry) BelongsTo(bskyDid string, bskyHandle string) bool {
+	if e.Did != "" {
+		return e.Did == bskyDid
+	}
+	return bskyHandle != "" && e.Handle == bskyHandle
+}
+
+func Store(naming Naming, verificationId string, bskyDid string, bskyHandle string, proofMode string, levelLabels []string) error {
+	LogInfo("Storing verified user in kv store", Field("did", bskyDid), Field("module", naming.Key))
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	key := naming.Key + "-" + verificationId
+	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+
+	entry := StoreEntry{
+		Did:                 bskyDid,
+		Handle:              bskyHandle,
+		ModuleKey:           naming.Key,
+		VerificationId:      verificationId,
+		FirstVerifiedAt:     timestamp,
+		LastValidatedAt:     timestamp,
+		FirstAndSecondLevel: map[string][]string{},
+		AppVersion:          AppVersion(),
+		ProofMode:           proofMode,
+		LevelLabels:         levelLabels,
+	}
+	for first, secondArray := range naming.FirstAndSecondLevel {
+		entry.FirstAndSecondLevel[first.Title] = make([]string, len(secondArray))
+		for i, second := range secondArray {
+			entry.FirstAndSecondLevel[first.Title][i] = second.Title
+		}
+	}
+
+	// keep the original verification date if the same account verifies again
+	existing, err := store.Get(key)
+	if err == nil {
+		existingEntry := ParseStoreEntry(existing)
+		// only a link at the source proves that the ID moved to another account, otherwise the owner stays
+		if !existingEntry.BelongsTo(bskyDid, bskyHandle) && proofMode != ProofModeLink && proofMode != "" {
+			return fmt.Errorf("ID " + verificationId + " is already verified for another Bluesky account")
+		}
+		if existingEntry.Did == bskyDid && existingEntry.FirstVerifiedAt != "" {
+			entry.FirstVerifiedAt = existingEntry.FirstVerifiedAt
+		}
+	}
+
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return store.Set(key, value)
+}
+
+// MarkValidated sets the last validation timestamp of a stored verification to now
+func MarkValidated(key string) error {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	value, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+
+	entry := ParseStoreEntryWithKey(key, value)
+	entry.LastValidatedAt = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	if entry.AppVersion == "" {
+		entry.AppVersion = AppVersion()
+	}
+
+	newValue, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return store.Set(key, newValue)
+}
+
+// UpdateStoredHandle changes the handle of a stored verification after the account was renamed
+func UpdateStoredHandle(key string, bskyHandle string) error {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	value, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+
+	entry := ParseStoreEntryWithKey(key, value)
+	LogInfo("Updating handle", Field("key", key), Field("from", entry.Handle), Field("to", bskyHandle))
+	entry.Handle = bskyHandle
+
+	newValue, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return store.Set(key, newValue)
+}
+
+// VerifiedForOtherAccount checks if the verificationId of the module is already stored for a different Bluesky account
+func VerifiedForOtherAccount(moduleKey string, verificationId string, bskyDid string, bskyHandle string) (bool, error) {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return false, err
+	}
+	defer store.Close()
+
+	value, err := store.Get(moduleKey + "-" + verificationId)
+	if err != nil {
+		if err.Error() == "no such key" {
+			return false, nil
+		}
+		return false, err
+	}
+	return !ParseStoreEntry(value).BelongsTo(bskyDid, bskyHandle), nil
+}
+
+func CheckStore(naming Naming, verificationId string, bskyDid string) (bool, error) {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return false, err
+	}
+	defer store.Close()
+
+	key := naming.Key + "-" + verificationId
+
+	exists, err := store.Exists(key)
+	if (err != nil) || (!exists) {
+		return false, err
+	}
+
+	value, err := store.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return ParseStoreEntry(value).Did == bskyDid, nil
+}
+
+func DeleteFromStore(naming Naming, verificationId string) error {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	key := naming.Key + "-" + verificationId
+
+	exists, err := store.Exists(key)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		err = store.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateStoreToDids resolves the DID for all legacy entries that only contain a handle
+// and rewrites them in the current format. It returns the number of migrated entries and
+// the keys that could not be migrated.
+func MigrateStoreToDids(accessJwt string, endpoint string) (int, []string, error) {
+	store, err := kv.OpenStore("default")
+	if err != nil {
+		return 0, []string{}, err
+	}
+	defer store.Close()
+
+	keys, err := store.GetKeys()
+	if err != nil {
+		return 0, []string{}, err
+	}
+
+	migrated := 0
+	failed := []string{}
+	for _, key := range keys {
+		if !IsVerificationKey(key) {
+			continue
+		}
+		value, err := store.Get(key)
+		if err != nil {
+			return migrated, failed, err
+		}
+		entry := ParseStoreEntryWithKey(key, value)
+		if entry.Did != "" {
+			continue
+		}
+
+		LogInfo("Migrating entry", Field("key", key), Field("handle", entry.Handle))
+		profile, err := GetProfile(entry.Handle, accessJwt, endpoint)
+		if err != nil || profile.DID == "" {
+			LogWarn("Could not resolve DID for handle", Field("key", key), Field("handle", entry.Handle))
+			failed = append(failed, key)
+			continue
+		}
+		entry.Did = profile.DID
+		entry.AppVersion = AppVersion()
+
+		newValue, err := json.Marshal(entry)
+		if err != nil {
+			return migrated, failed, err
+		}
+		err = store.Set(key, newValue)
+		if err != nil {
+			return migrated, failed, err
+		}
+		migrated++
+	}
+	return migrated, failed, nil
+}
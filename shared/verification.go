@@ -2,53 +2,37 @@ package shared
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/fermyon/spin/sdk/go/v2/kv"
 	"github.com/fermyon/spin/sdk/go/v2/variables"
 )
 
 type ModuleSpecifics struct {
-	ModuleKey            string
-	ModuleName           string
-	ModuleNameShortened  string
-	ModuleLabel          string
-	ExplanationText      string
-	VerificationFunc     func(verificationId string, bskyHandle string) (bool, error)
+	ModuleKey           string
+	ModuleName          string
+	ModuleNameShortened string
+	ModuleLabel         string
+	ExplanationText     string
+	VerificationFunc    func(verificationId string, bskyHandle string, bskyDid string) (bool, error)
+	ExistenceFunc       func(verificationId string) (bool, error)
+	// optional: checks if the challenge token is on the profile at the source, without it challenges need an admin approval
+	SourceTokenFunc      func(verificationId string, token string) (bool, error)
+	ChallengeSupported   bool
 	NamingFunc           func(m ModuleSpecifics, verificationId string) (Naming, error)
 	FirstAndSecondLevel  map[string][]string
 	Level1TranslationMap map[string]string
 	Level2TranslationMap map[string]string
-}
-
-// GetModuleSpecifics returns the ModuleSpecifics for a given moduleKey
-func GetModuleSpecifics(moduleKey string) (ModuleSpecifics, error) {
-	switch moduleKey {
-	case "mvp":
-		return getMvpModuleSpecifics(), nil
-	case "awshero":
-		return getAwsHeroModuleSpecifics(), nil
-	case "rd":
-		return getRdModuleSpecifics(), nil
-	case "ghstar":
-		return getGhStarModuleSpecifics(), nil
-	case "javachamps":
-		return getJavaChampsModuleSpecifics(), nil
-	case "ibmchamp":
-		return getIbmChampModuleSpecifics(), nil
-	case "oracleace":
-		return getOracleAceModuleSpecifics(), nil
-	case "cncfamb":
-		return getCncfAmbModuleSpecifics(), nil
-	case "afm":
-		return getAfmModuleSpecifics(), nil
-	default:
-		return ModuleSpecifics{}, fmt.Errorf("unknown module key: %s", moduleKey)
-	}
+	// optional descriptions of the label, generated from the module name if empty
+	LabelLocales []LabelLocale
+	// adds a label per first level, e.g. mvp-ai-platform, next to the module label
+	LevelLabelsEnabled bool
+	// labels expire after this period unless the verification is validated again, 0 means they never expire
+	AwardPeriod time.Duration
 }
 
 // Module-specific configurations
@@ -232,6 +216,8 @@ func getMvpModuleSpecifics() ModuleSpecifics {
 		ModuleName:           "Microsoft Most Valuable Professionals (MVPs)",
 		ModuleNameShortened:  "MVPs",
 		ModuleLabel:          "ms-mvp",
+		LevelLabelsEnabled:   true,
+		AwardPeriod:          365 * 24 * time.Hour,
 		ExplanationText:      "This is your MVP ID, a GUID. If you open your profile on <a href=\"https://mvp.microsoft.com\" target=\"_blank\">mvp.microsoft.com</a>, it is the last part of the URL, after the last /. For this to work, you need to have the link to your Bluesky profile in the list of social networks on your MVP profile (use \"Other\" as type).",
 		FirstAndSecondLevel:  mvpAwardsAndTechnologyFocusAreas,
 		Level1TranslationMap: mvpAwardTranslationMap,
@@ -239,19 +225,6 @@ func getMvpModuleSpecifics() ModuleSpecifics {
 	}
 }
 
-func getAwsHeroModuleSpecifics() ModuleSpecifics {
-	return ModuleSpecifics{
-		ModuleKey:            "awshero",
-		ModuleName:           "AWS Heroes",
-		ModuleNameShortened:  "AWS Heroes",
-		ModuleLabel:          "awshero",
-		ExplanationText:      "This is your AWS Heroes alias / handle. For this to work, you need to have the link to your Bluesky profile in the social links on your AWS Hero profile.",
-		FirstAndSecondLevel:  make(map[string][]string),
-		Level1TranslationMap: make(map[string]string),
-		Level2TranslationMap: make(map[string]string),
-	}
-}
-
 func getRdModuleSpecifics() ModuleSpecifics {
 	return ModuleSpecifics{
 		ModuleKey:            "rd",
@@ -271,6 +244,7 @@ func getGhStarModuleSpecifics() ModuleSpecifics {
 		ModuleName:           "Github Stars",
 		ModuleNameShortened:  "GitHub Stars",
 		ModuleLabel:          "ghstar",
+		AwardPeriod:          365 * 24 * time.Hour,
 		ExplanationText:      "This is your ID in the Github Stars list. If you open your profile, it is the last part of the URL after https://stars.github.com/profiles/ and without the / in the end. For this to work, you need to have the link to your Bluesky profile in the Additional links on your Github Stars profile.",
 		FirstAndSecondLevel:  make(map[string][]string),
 		Level1TranslationMap: make(map[string]string),
@@ -288,65 +262,58 @@ func getJavaChampsModuleSpecifics() ModuleSpecifics {
 		FirstAndSecondLevel:  make(map[string][]string),
 		Level1TranslationMap: make(map[string]string),
 		Level2TranslationMap: make(map[string]string),
+		ChallengeSupported:   true,
 	}
 }
 
-func getIbmChampModuleSpecifics() ModuleSpecifics {
+func getAfmModuleSpecifics() ModuleSpecifics {
 	return ModuleSpecifics{
-		ModuleKey:            "ibmchamp",
-		ModuleName:           "IBM Champions",
-		ModuleNameShortened:  "IBM Champions",
-		ModuleLabel:          "ibmchamp",
-		ExplanationText:      "This is your ID in the IBM Champions list. If you open your profile, it is the last part of the URL after https://community.ibm.com/community/user/champions/expert/. For this to work, you need to have the link to your Bluesky profile in the social links on your IBM Champion profile.",
+		ModuleKey:            "afm",
+		ModuleName:           "Apache Foundation Members",
+		ModuleNameShortened:  "Apache Foundation Members",
+		ModuleLabel:          "afm",
+		ExplanationText:      "This is your ID in the Apache Foundation Members list. You can find it at https://www.apache.org/foundation/members.html. For this to work, you need to have the link to your Bluesky profile in the social links in the Apache Foundation Members phonebook at https://people.apache.org/phonebook.html.",
 		FirstAndSecondLevel:  make(map[string][]string),
 		Level1TranslationMap: make(map[string]string),
 		Level2TranslationMap: make(map[string]string),
+		ChallengeSupported:   true,
 	}
 }
 
-func getOracleAceModuleSpecifics() ModuleSpecifics {
+// Sessionize events whose speakers can be verified, the key is the Sessionize API ID of the event
+// (https://sessionize.com/api/v2/<id>/view/Speakers) and the value the event name used for the lists.
+// Loaded from the Spin variable sessionize_events as JSON object
+var SessionizeEvents = map[string]string{}
 
-	aceLevels := map[string][]string{
-		"Associate": {},
-		"Pro":       {},
-		"Director":  {},
+func loadSessionizeEvents() {
+	SessionizeEvents = map[string]string{}
+	value, err := variables.Get("sessionize_events")
+	if err != nil || strings.TrimSpace(value) == "" {
+		return
 	}
-
-	return ModuleSpecifics{
-		ModuleKey:            "oracleace",
-		ModuleName:           "Oracle ACEs",
-		ModuleNameShortened:  "Oracle ACEs",
-		ModuleLabel:          "oracleace",
-		ExplanationText:      "This is your ID in the Oracle ACEs list. This is the last part of the URL after https://apexadb.oracle.com/ords/ace/profile/. For this to work, you need to have the link to your Bluesky profile in the Social links on your Oracle ACE profile.",
-		FirstAndSecondLevel:  aceLevels,
-		Level1TranslationMap: make(map[string]string),
-		Level2TranslationMap: make(map[string]string),
+	err = json.Unmarshal([]byte(value), &SessionizeEvents)
+	if err != nil {
+		LogError("Error decoding Sessionize events", Field("error", err))
+		SessionizeEvents = map[string]string{}
 	}
 }
 
-func getCncfAmbModuleSpecifics() ModuleSpecifics {
-	return ModuleSpecifics{
-		ModuleKey:            "cncfamb",
-		ModuleName:           "CNCF Ambassadors",
-		ModuleNameShortened:  "CNCF Ambassadors",
-		ModuleLabel:          "cncfamb",
-		ExplanationText:      "This is your ID in the CNCF Ambassadors list. If you open your profile, it is the last part of the URL after https://www.cncf.io/people/ambassadors/?p=. For this to work, you need to have the link to your Bluesky profile in the social links on your CNCF Ambassador profile.",
-		FirstAndSecondLevel:  make(map[string][]string),
-		Level1TranslationMap: make(map[string]string),
-		Level2TranslationMap: make(map[string]string),
+func getSessionizeModuleSpecifics() ModuleSpecifics {
+	events := map[string][]string{}
+	for _, eventName := range SessionizeEvents {
+		events[eventName] = []string{}
 	}
-}
 
-func getAfmModuleSpecifics() ModuleSpecifics {
 	return ModuleSpecifics{
-		ModuleKey:            "afm",
-		ModuleName:           "Apache Foundation Members",
-		ModuleNameShortened:  "Apache Foundation Members",
-		ModuleLabel:          "afm",
-		ExplanationText:      "This is your ID in the Apache Foundation Members list. You can find it at https://www.apache.org/foundation/members.html. For this to work, you need to have the link to your Bluesky profile in the social links in the Apache Foundation Members phonebook at https://people.apache.org/phonebook.html.",
-		FirstAndSecondLevel:  make(map[string][]string),
+		ModuleKey:            "sessionize",
+		ModuleName:           "Conference Speakers",
+		ModuleNameShortened:  "Speakers",
+		ModuleLabel:          "speaker",
+		ExplanationText:      "This is your Sessionize speaker ID, a GUID, or your full name exactly as it appears on your Sessionize speaker profile for the event. For this to work, you need to have the link to your Bluesky profile in the links on your Sessionize profile, either as Bluesky link or with \"Other\" as type.",
+		FirstAndSecondLevel:  events,
 		Level1TranslationMap: make(map[string]string),
 		Level2TranslationMap: make(map[string]string),
+		ChallengeSupported:   true,
 	}
 }
 
@@ -377,7 +344,7 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 					fmt.Fprintln(w, m.ExplanationText)
 					return
 				}
-				fmt.Println("Getting Starter Pack and List for " + title)
+				LogInfo("Getting Starter Pack and List", Field("title", title))
 				accessJwt, endpoint, err := LoginToBsky()
 				if err != nil {
 					http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -395,7 +362,7 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		fmt.Println("Getting all Starter Packs and Lists")
+		LogInfo("Getting all Starter Packs and Lists")
 		accessJwt, endpoint, err := LoginToBsky()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -423,15 +390,7 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		// verify externally
-		fmt.Println("Validating with external service")
-		verified, err := m.VerificationFunc(validationRequest.VerificationId, validationRequest.BskyHandle)
-		if !verified {
-			http.Error(w, "Verification failed: "+err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		// get bsky profile
+		// get bsky profile, the DID is needed to accept links to it as well
 		accessJwt, endpoint, err := LoginToBsky()
 
 		profile, err := GetProfile(validationRequest.BskyHandle, accessJwt, endpoint)
@@ -444,19 +403,114 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		naming, err := m.NamingFunc(m, validationRequest.VerificationId)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		proofMode := validationRequest.ProofMode
+		if proofMode == "" {
+			proofMode = ProofModeLink
 		}
 
-		if verifyOnly != "true" {
-			// store in kv store
-			err = Store(naming, validationRequest.VerificationId, validationRequest.BskyHandle)
-			if err != nil {
-				http.Error(w, "Error storing user in k/v store: "+err.Error(), http.StatusInternalServerError)
+		needsApproval := false
+		switch proofMode {
+		case ProofModeLink:
+			// verify externally
+			LogInfo("Validating with external service", Field("module", m.ModuleKey), Field("verificationId", validationRequest.VerificationId))
+			verified, err := m.VerificationFunc(validationRequest.VerificationId, profile.Handle, profile.DID)
+			if !verified {
+				writeVerificationFailure(w, err)
+				return
+			}
+
+		case ProofModeChallenge, ProofModeExistence:
+			if m.ExistenceFunc == nil {
+				http.Error(w, "Proof mode "+proofMode+" is not supported for "+m.ModuleName, http.StatusBadRequest)
+				return
+			}
+			if proofMode == ProofModeExistence && verifyOnly != "true" {
+				http.Error(w, "Proof mode "+proofMode+" is only allowed in verify_only mode", http.StatusBadRequest)
+				return
+			}
+
+			LogInfo("Checking existence with external service", Field("module", m.ModuleKey), Field("verificationId", validationRequest.VerificationId))
+			exists, err := m.ExistenceFunc(validationRequest.VerificationId)
+			if !exists {
+				writeVerificationFailure(w, err)
 				return
 			}
+
+			if proofMode == ProofModeChallenge {
+				// the token only proves control of the Bluesky account, so it can't take over an ID of somebody else
+				otherAccount, err := VerifiedForOtherAccount(m.ModuleKey, validationRequest.VerificationId, profile.DID, profile.Handle)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if otherAccount {
+					http.Error(w, "Verification failed: "+m.ModuleName+" ID "+validationRequest.VerificationId+" is already verified for another Bluesky account", http.StatusConflict)
+					return
+				}
+
+				sourceProof := m.SourceTokenFunc != nil
+				challenge, found, err := GetOpenChallenge(m.ModuleKey, validationRequest.VerificationId, profile.DID)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if !found {
+					challengeResponse, err := IssueChallenge(m.ModuleKey, validationRequest.VerificationId, profile.DID, sourceProof)
+					if err != nil {
+						http.Error(w, "Error issuing challenge: "+err.Error(), http.StatusInternalServerError)
+						return
+					}
+					jsonResult, err := json.Marshal(challengeResponse)
+					if err != nil {
+						http.Error(w, "Error encoding result to JSON: "+err.Error(), http.StatusInternalServerError)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusAccepted)
+					fmt.Fprintln(w, string(jsonResult))
+					return
+				}
+
+				carriesToken, err := AccountCarriesToken(profile.DID, challenge.Token, accessJwt, endpoint)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if !carriesToken {
+					http.Error(w, "Verification failed: the challenge token was not found. "+challenge.response(sourceProof).Message, http.StatusBadRequest)
+					return
+				}
+				if sourceProof {
+					LogInfo("Checking the challenge token at the source", Field("module", m.ModuleKey), Field("verificationId", validationRequest.VerificationId))
+					carriesToken, err := m.SourceTokenFunc(validationRequest.VerificationId, challenge.Token)
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					}
+					if !carriesToken {
+						http.Error(w, "Verification failed: the challenge token was not found on the "+m.ModuleName+" profile. "+challenge.response(sourceProof).Message, http.StatusBadRequest)
+						return
+					}
+				} else {
+					needsApproval = true
+				}
+				if verifyOnly != "true" {
+					err = DeleteChallenge(m.ModuleKey, profile.DID)
+					if err != nil {
+						LogWarn("Error deleting challenge", Field("did", profile.DID), Field("error", err))
+					}
+				}
+			}
+
+		default:
+			http.Error(w, "Unknown proof mode "+proofMode, http.StatusBadRequest)
+			return
+		}
+
+		naming, err := m.NamingFunc(m, validationRequest.VerificationId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		result := []ListOrStarterPackWithUrl{}
@@ -478,10 +532,25 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 					})
 				}
 			}
+		} else if needsApproval {
+			approvalResponse, err := QueueForApproval(naming, validationRequest.VerificationId, profile.DID, profile.Handle, proofMode, m.ModuleLabel, m.LevelLabels(naming))
+			if err != nil {
+				http.Error(w, "Error queueing the verification for approval: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			jsonResult, err := json.Marshal(approvalResponse)
+			if err != nil {
+				http.Error(w, "Error encoding result to JSON: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, string(jsonResult))
+			return
 		} else {
-			// add to bsky starter pack
-			fmt.Println("Adding verified user to Bluesky starter pack")
-			result, err = AddToBskyStarterPacksAndList(naming, validationRequest.VerificationId, validationRequest.BskyHandle, profile.DID, m.ModuleLabel, accessJwt, endpoint)
+			// store in kv store, add to bsky starter packs and lists, follow and label, rolled back if a step fails
+			LogInfo("Adding verified user to Bluesky starter packs and lists", Field("did", profile.DID))
+			result, err = RunVerificationPipeline(naming, validationRequest.VerificationId, profile.DID, profile.Handle, proofMode, m.ModuleLabel, m.LevelLabels(naming), accessJwt, endpoint)
 
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -511,7 +580,7 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		fmt.Println("Setting up all Starter Packs and Lists")
+		LogInfo("Setting up all Starter Packs and Lists", Field("module", m.ModuleKey))
 		err = SetupAllStarterPacksAndLists(m, w, accessJwt, endpoint)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -541,7 +610,13 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		isInStore, err := CheckStore(naming, validationRequest.VerificationId, validationRequest.BskyHandle)
+		profile, err := GetProfile(validationRequest.BskyHandle, accessJwt, endpoint)
+		if err != nil {
+			http.Error(w, "Error getting profile: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		isInStore, err := CheckStore(naming, validationRequest.VerificationId, profile.DID)
 		if err != nil {
 			http.Error(w, "Error checking if User is in store: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -551,7 +626,7 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		fmt.Println("Delete user from all Starter Packs and Lists")
+		LogInfo("Deleting user from all Starter Packs and Lists", Field("module", m.ModuleKey))
 
 		allLists, err := GetLists(accessJwt, endpoint)
 		if err != nil {
@@ -565,7 +640,7 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 		}
 
 		name := naming.Title
-		err = DeleteUserFromStarterPacksAndListWithName(name, validationRequest.BskyHandle, allLists, allStarterPacks, accessJwt, endpoint)
+		err = DeleteUserFromStarterPacksAndListWithName(name, profile.DID, allLists, allStarterPacks, accessJwt, endpoint)
 		if err != nil {
 			http.Error(w, "Error deleting user "+validationRequest.BskyHandle+" from starter packs and lists "+name+" (root level): "+err.Error(), http.StatusInternalServerError)
 			return
@@ -573,7 +648,7 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 
 		for firstLevel := range naming.FirstAndSecondLevel {
 			name = firstLevel.Title
-			err = DeleteUserFromStarterPacksAndListWithName(name, validationRequest.BskyHandle, allLists, allStarterPacks, accessJwt, endpoint)
+			err = DeleteUserFromStarterPacksAndListWithName(name, profile.DID, allLists, allStarterPacks, accessJwt, endpoint)
 			if err != nil {
 				http.Error(w, "Error deleting user "+validationRequest.BskyHandle+" from starter packs and lists "+name+" (first level): "+err.Error(), http.StatusInternalServerError)
 				return
@@ -581,7 +656,7 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 
 			for _, secondLevel := range naming.FirstAndSecondLevel[firstLevel] {
 				name = secondLevel.Title
-				err = DeleteUserFromStarterPacksAndListWithName(name, validationRequest.BskyHandle, allLists, allStarterPacks, accessJwt, endpoint)
+				err = DeleteUserFromStarterPacksAndListWithName(name, profile.DID, allLists, allStarterPacks, accessJwt, endpoint)
 				if err != nil {
 					http.Error(w, "Error deleting user "+validationRequest.BskyHandle+" from starter packs and lists "+name+" (second level): "+err.Error(), http.StatusInternalServerError)
 					return
@@ -600,6 +675,27 @@ func (m ModuleSpecifics) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// writeVerificationFailure responds with the reason per event as JSON for Sessionize speakers, otherwise as text
+func writeVerificationFailure(w http.ResponseWriter, err error) {
+	if err == nil {
+		http.Error(w, "Verification failed", http.StatusBadRequest)
+		return
+	}
+	var speakerError *SessionizeSpeakerError
+	if !errors.As(err, &speakerError) {
+		http.Error(w, "Verification failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	jsonResponse, err := json.Marshal(speakerError)
+	if err != nil {
+		http.Error(w, "Error encoding result to JSON: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintln(w, string(jsonResponse))
+}
+
 func DeleteUserFromStarterPacksAndListWithName(listName string, userToDelete string, allLists []List, allStarterPacks []StarterPack, accessJwt string, endpoint string) error {
 	for _, list := range allLists {
 		if list.Name == listName {
@@ -651,60 +747,3 @@ func GetBody(r *http.Request, w http.ResponseWriter) (ValidationRequest, error)
 	validationRequest.BskyHandle = strings.ToLower(validationRequest.BskyHandle)
 	return validationRequest, nil
 }
-
-func Store(naming Naming, moduleKey string, bskyHandle string) error {
-	fmt.Println("Storing verified user in kv store")
-	store, err := kv.OpenStore("default")
-	if err != nil {
-		return err
-	}
-	defer store.Close()
-
-	key := naming.Key + "-" + moduleKey
-
-	return store.Set(key, []byte(bskyHandle))
-}
-
-func CheckStore(naming Naming, moduleKey string, bskyHandle string) (bool, error) {
-	store, err := kv.OpenStore("default")
-	if err != nil {
-		return false, err
-	}
-	defer store.Close()
-
-	key := naming.Key + "-" + moduleKey
-
-	exists, err := store.Exists(key)
-	if (err != nil) || (!exists) {
-		return false, err
-	}
-
-	value, err := store.Get(key)
-	if err != nil {
-		return false, err
-	}
-	return string(value) == bskyHandle, nil
-}
-
-func DeleteFromStore(naming Naming, moduleKey string) error {
-	store, err := kv.OpenStore("default")
-	if err != nil {
-		return err
-	}
-	defer store.Close()
-
-	key := naming.Key + "-" + moduleKey
-
-	exists, err := store.Exists(key)
-	if err != nil {
-		return err
-	}
-
-	if exists {
-		err = store.Delete(key)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
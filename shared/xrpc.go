@@ -0,0 +1,249 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// XrpcClient calls XRPC queries and procedures on a service with JSON marshalled requests and responses
+type XrpcClient struct {
+	Endpoint  string
+	AccessJwt string
+	Headers   map[string]string
+	// don't log the request, e.g. for credentials or frequent calls
+	NoLog bool
+}
+
+// XrpcError is the error body an XRPC service returns together with a non-200 status code
+type XrpcError struct {
+	StatusCode int           `json:"-"`
+	ErrorName  string        `json:"error"`
+	Message    string        `json:"message"`
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *XrpcError) Error() string {
+	if e.ErrorName == "" && e.Message == "" {
+		return fmt.Sprintf("XRPC request failed with status code %d", e.StatusCode)
+	}
+	return fmt.Sprintf("XRPC request failed with status code %d: %s %s", e.StatusCode, e.ErrorName, e.Message)
+}
+
+func NewXrpcClient(endpoint string, accessJwt string) *XrpcClient {
+	return &XrpcClient{Endpoint: strings.TrimRight(endpoint, "/"), AccessJwt: accessJwt, Headers: map[string]string{}}
+}
+
+// NewAppViewClient returns a client for app.bsky.* queries that the PDS forwards to the configured AppView
+func NewAppViewClient(endpoint string, accessJwt string) *XrpcClient {
+	return NewXrpcClient(endpoint, accessJwt).WithHeaders(map[string]string{"atproto-proxy": AppViewProxy()})
+}
+
+// WithHeaders returns a copy of the client that sends the additional headers with every request
+func (c *XrpcClient) WithHeaders(headers map[string]string) *XrpcClient {
+	clone := *c
+	clone.Headers = map[string]string{}
+	for key, value := range c.Headers {
+		clone.Headers[key] = value
+	}
+	for key, value := range headers {
+		clone.Headers[key] = value
+	}
+	return &clone
+}
+
+// Query sends a GET request for the nsid and decodes the response into out if it is not nil
+func (c *XrpcClient) Query(nsid string, params url.Values, out interface{}) error {
+	requestUrl := c.Endpoint + "/xrpc/" + nsid
+	if len(params) > 0 {
+		requestUrl += "?" + params.Encode()
+	}
+	if !c.NoLog {
+		LogInfo("Sending XRPC query", Field("nsid", nsid), Field("url", RedactUrl(requestUrl)))
+	}
+
+	request, err := http.NewRequest("GET", requestUrl, nil)
+	if err != nil {
+		LogError("Error creating GET request", Field("error", err))
+		return err
+	}
+	return c.send(request, true, out)
+}
+
+// Procedure sends a POST request with in as JSON body for the nsid and decodes the response into out if it is not nil.
+// If in is nil, the request has no body
+func (c *XrpcClient) Procedure(nsid string, in interface{}, out interface{}) error {
+	requestUrl := c.Endpoint + "/xrpc/" + nsid
+	payload := []byte{}
+	if in != nil {
+		var err error
+		payload, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+	if !c.NoLog {
+		LogInfo("Sending XRPC procedure", Field("nsid", nsid), Field("url", RedactUrl(requestUrl)))
+		LogDebug("XRPC procedure payload", Field("nsid", nsid), Field("payload", RedactPayload(string(payload))))
+	}
+
+	request, err := http.NewRequest("POST", requestUrl, strings.NewReader(string(payload)))
+	if err != nil {
+		LogError("Error creating POST request", Field("error", err))
+		return err
+	}
+	if in != nil {
+		request.Header.Add("Content-Type", "application/json")
+	}
+	return c.send(request, false, out)
+}
+
+func (c *XrpcClient) send(request *http.Request, idempotent bool, out interface{}) error {
+	if c.AccessJwt != "" {
+		request.Header.Add("Authorization", "Bearer "+c.AccessJwt)
+	}
+	for key, value := range c.Headers {
+		request.Header.Add(key, value)
+	}
+
+	resp, err := sendWithRetry(request, idempotent)
+	if err != nil {
+		LogError("Error sending XRPC request", Field("method", request.Method), Field("url", RedactUrl(request.URL.String())), Field("error", err))
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		xrpcError := &XrpcError{}
+		if json.Unmarshal(body, xrpcError) != nil {
+			xrpcError.Message = string(body)
+		}
+		xrpcError.StatusCode = resp.StatusCode
+		xrpcError.RetryAfter = retryAfter(resp)
+		LogWarn("XRPC request failed", Field("method", request.Method), Field("url", RedactUrl(request.URL.String())), Field("status", resp.StatusCode), Field("error", xrpcError.ErrorName), Field("message", xrpcError.Message))
+		return xrpcError
+	}
+
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// Request and record types of the XRPC endpoints
+
+type CreateSessionRequest struct {
+	Identifier string `json:"identifier"`
+	Password   string `json:"password"`
+}
+
+type CreateRecordRequest struct {
+	Repo       string      `json:"repo"`
+	Collection string      `json:"collection"`
+	Rkey       string      `json:"rkey,omitempty"`
+	Record     interface{} `json:"record"`
+}
+
+type PutRecordRequest struct {
+	Repo       string      `json:"repo"`
+	Collection string      `json:"collection"`
+	Rkey       string      `json:"rkey"`
+	Record     interface{} `json:"record"`
+}
+
+type DeleteRecordRequest struct {
+	Repo       string `json:"repo"`
+	Collection string `json:"collection"`
+	Rkey       string `json:"rkey"`
+}
+
+type ApplyWritesRequest struct {
+	Repo   string             `json:"repo"`
+	Writes []ApplyWritesWrite `json:"writes"`
+}
+
+type ApplyWritesWrite struct {
+	Type       string      `json:"$type"`
+	Collection string      `json:"collection"`
+	Rkey       string      `json:"rkey,omitempty"`
+	Value      interface{} `json:"value,omitempty"`
+}
+
+type ListRecord struct {
+	Type        string `json:"$type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Purpose     string `json:"purpose"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+type ListItemRecord struct {
+	Type      string `json:"$type"`
+	Subject   string `json:"subject"`
+	List      string `json:"list"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type StarterPackRecord struct {
+	Type        string        `json:"$type"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	List        string        `json:"list"`
+	Feeds       []interface{} `json:"feeds"`
+	CreatedAt   string        `json:"createdAt"`
+	UpdatedAt   string        `json:"updatedAt,omitempty"`
+}
+
+type FollowRecord struct {
+	Type      string `json:"$type"`
+	Subject   string `json:"subject"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type RepoRef struct {
+	Type string `json:"$type"`
+	Did  string `json:"did"`
+}
+
+type EmitEventRequest struct {
+	Subject         RepoRef     `json:"subject"`
+	CreatedBy       string      `json:"createdBy"`
+	SubjectBlobCids []string    `json:"subjectBlobCids"`
+	Event           interface{} `json:"event"`
+}
+
+type ModEventLabel struct {
+	Type            string   `json:"$type"`
+	CreateLabelVals []string `json:"createLabelVals"`
+	NegateLabelVals []string `json:"negateLabelVals"`
+	DurationInHours int      `json:"durationInHours,omitempty"`
+}
+
+type ModEventAcknowledge struct {
+	Type string `json:"$type"`
+}
+
+type ConvoResponse struct {
+	Convo struct {
+		ID string `json:"id"`
+	} `json:"convo"`
+}
+
+type SendMessageRequest struct {
+	ConvoId string       `json:"convoId"`
+	Message MessageInput `json:"message"`
+}
+
+type MessageInput struct {
+	Type string `json:"$type"`
+	Text string `json:"text"`
+}
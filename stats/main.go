@@ -4,17 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 
 	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
 	"github.com/fermyon/spin/sdk/go/v2/kv"
+	"github.com/shared"
 )
 
 func init() {
 	spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			fmt.Println("Getting stats")
+			shared.LogInfo("Getting stats")
 			store, err := kv.OpenStore("default")
 			if err != nil {
 				http.Error(w, "Error opening kv store "+err.Error(), http.StatusInternalServerError)
@@ -22,7 +22,7 @@ func init() {
 			}
 			defer store.Close()
 
-			fmt.Println("Getting keys")
+			shared.LogDebug("Getting keys")
 			keys, err := store.GetKeys()
 			if err != nil {
 				http.Error(w, "Error getting keys from kv store "+err.Error(), http.StatusInternalServerError)
@@ -31,20 +31,25 @@ func init() {
 
 			typeCounters := make(map[string]int)
 
-			fmt.Println("Counting")
+			shared.LogDebug("Counting")
 			for _, key := range keys {
-				keyPrefix := strings.Split(key, "-")[0]
-				if (keyPrefix == "accessJwt") || (keyPrefix == "endpoint") {
+				if !shared.IsVerificationKey(key) {
 					continue
 				}
-				if _, ok := typeCounters[keyPrefix]; !ok {
-					typeCounters[keyPrefix] = 1
+				value, err := store.Get(key)
+				if err != nil {
+					http.Error(w, "Error getting value from kv store "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				moduleKey := shared.ParseStoreEntryWithKey(key, value).ModuleKey
+				if _, ok := typeCounters[moduleKey]; !ok {
+					typeCounters[moduleKey] = 1
 				} else {
-					typeCounters[keyPrefix]++
+					typeCounters[moduleKey]++
 				}
 			}
 
-			fmt.Println("Replying")
+			shared.LogDebug("Replying")
 			jsonResult, err := json.Marshal(typeCounters)
 			if err != nil {
 				http.Error(w, "Error encoding result to JSON: "+err.Error(), http.StatusInternalServerError)
@@ -3,11 +3,8 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/shared"
-
-	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
 )
 
 type memberInfoResponse struct {
@@ -24,10 +21,8 @@ type phonebookEntry struct {
 }
 
 func init() {
-	moduleSpecifics, _ := shared.GetModuleSpecifics("afm")
-
-	moduleSpecifics.VerificationFunc = func(verificationId string, bskyHandle string) (bool, error) {
-		fmt.Println("Validating Apache Foundation Member with ID: " + verificationId)
+	verificationFunc := func(verificationId string, bskyHandle string, bskyDid string) (bool, error) {
+		shared.LogInfo("Validating Apache Foundation Member", shared.Field("verificationId", verificationId))
 
 		if err := ensureIsMember(verificationId); err != nil {
 			return false, err
@@ -38,19 +33,23 @@ func init() {
 			return false, err
 		}
 
-		if containsBlueskyURL(entry.URLs, bskyHandle) {
-			fmt.Print("Bluesky link found in phonebook entry\n")
+		if containsBlueskyURL(entry.URLs, bskyHandle, bskyDid) {
+			shared.LogInfo("Bluesky link found in phonebook entry", shared.Field("verificationId", verificationId))
 			return true, nil
 		}
 
 		return false, fmt.Errorf("Bluesky link https://bsky.app/profile/%s not found for Apache Foundation Member %s", bskyHandle, verificationId)
 	}
 
-	moduleSpecifics.NamingFunc = func(m shared.ModuleSpecifics, _ string) (shared.Naming, error) {
-		return shared.SetupNamingStructure(m)
+	existenceFunc := func(verificationId string) (bool, error) {
+		shared.LogInfo("Checking if Apache Foundation Member exists", shared.Field("verificationId", verificationId))
+		if err := ensureIsMember(verificationId); err != nil {
+			return false, err
+		}
+		return true, nil
 	}
 
-	spinhttp.Handle(moduleSpecifics.Handle)
+	shared.ServeModuleWithExistence("afm", verificationFunc, nil, existenceFunc)
 }
 
 func ensureIsMember(verificationId string) error {
@@ -71,14 +70,14 @@ func ensureIsMember(verificationId string) error {
 func fetchMemberInfo() (memberInfoResponse, error) {
 	resp, err := shared.SendGet("https://whimsy.apache.org/public//member-info.json", "")
 	if err != nil {
-		fmt.Println("Error fetching member info: " + err.Error())
+		shared.LogError("Error fetching member info", shared.Field("error", err))
 		return memberInfoResponse{}, fmt.Errorf("Error fetching Apache Foundation member info: %w", err)
 	}
 	defer resp.Body.Close()
 
 	var memberInfo memberInfoResponse
 	if err := json.NewDecoder(resp.Body).Decode(&memberInfo); err != nil {
-		fmt.Println("Error decoding member info JSON: " + err.Error())
+		shared.LogError("Error decoding member info JSON", shared.Field("error", err))
 		return memberInfoResponse{}, fmt.Errorf("Error decoding Apache Foundation member info JSON: %w", err)
 	}
 
@@ -88,14 +87,14 @@ func fetchMemberInfo() (memberInfoResponse, error) {
 func getPhonebookEntry(verificationId string) (phonebookEntry, error) {
 	resp, err := shared.SendGet("https://whimsy.apache.org/public//public_ldap_people.json", "")
 	if err != nil {
-		fmt.Println("Error fetching phonebook: " + err.Error())
+		shared.LogError("Error fetching phonebook", shared.Field("error", err))
 		return phonebookEntry{}, fmt.Errorf("Error fetching Apache Foundation phonebook entry: %w", err)
 	}
 	defer resp.Body.Close()
 
 	var phonebook phonebookResponse
 	if err := json.NewDecoder(resp.Body).Decode(&phonebook); err != nil {
-		fmt.Println("Error decoding phonebook JSON: " + err.Error())
+		shared.LogError("Error decoding phonebook JSON", shared.Field("error", err))
 		return phonebookEntry{}, fmt.Errorf("Error decoding Apache Foundation phonebook JSON: %w", err)
 	}
 
@@ -107,14 +106,9 @@ func getPhonebookEntry(verificationId string) (phonebookEntry, error) {
 	return entry, nil
 }
 
-func containsBlueskyURL(urls []string, bskyHandle string) bool {
-	expected := "https://bsky.app/profile/" + bskyHandle
-	alt := "http://bsky.app/profile/" + bskyHandle
-	altNoScheme := "bsky.app/profile/" + bskyHandle
-
+func containsBlueskyURL(urls []string, bskyHandle string, bskyDid string) bool {
 	for _, u := range urls {
-		candidate := strings.TrimSpace(u)
-		if strings.EqualFold(candidate, expected) || strings.EqualFold(candidate, alt) || strings.EqualFold(candidate, altNoScheme) {
+		if shared.MatchesBlueskyProfile(u, bskyHandle, bskyDid) {
 			return true
 		}
 	}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net/http"
 
-	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
 	"github.com/shared"
 )
 
@@ -25,10 +24,8 @@ type GithubStarsResponse struct {
 }
 
 func init() {
-	moduleSpecifics, _ := shared.GetModuleSpecifics("ghstar")
-
-	moduleSpecifics.VerificationFunc = func(verificationId string, bskyHandle string) (bool, error) {
-		fmt.Println("Validating GitHub Star with ID: " + verificationId)
+	verificationFunc := func(verificationId string, bskyHandle string, bskyDid string) (bool, error) {
+		shared.LogInfo("Validating GitHub Star", shared.Field("verificationId", verificationId))
 
 		// GraphQL query to get the user's links
 		graphqlQuery := map[string]interface{}{
@@ -84,20 +81,16 @@ query GetStars($username: String!) {
 		}
 
 		// Check if the bskyHandle appears in any of the links
-		expectedURL := "https://bsky.app/profile/" + bskyHandle
 		for _, link := range result.Data.PublicProfile.Links {
-			if link.Link == expectedURL {
+			if shared.MatchesBlueskyProfile(link.Link, bskyHandle, bskyDid) {
 				return true, nil
 			}
 		}
 
 		return false, fmt.Errorf("bsky handle not found in GitHub Star profile")
 	}
-	moduleSpecifics.NamingFunc = func(m shared.ModuleSpecifics, _ string) (shared.Naming, error) {
-		return shared.SetupNamingStructure(m)
-	}
 
-	spinhttp.Handle(moduleSpecifics.Handle)
+	shared.ServeModule("ghstar", verificationFunc, nil)
 }
 
 func main() {}
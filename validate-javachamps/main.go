@@ -6,7 +6,6 @@ import (
 
 	"gopkg.in/yaml.v2"
 
-	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
 	"github.com/shared"
 )
 
@@ -35,54 +34,65 @@ type JCResponse struct {
 
 func init() {
 
-	moduleSpecifics, _ := shared.GetModuleSpecifics("javachamps")
-
-	moduleSpecifics.VerificationFunc = func(verificationId string, bskyHandle string) (bool, error) {
-		fmt.Println("Validating Java Champion with name: " + verificationId)
-		url := "https://javachampions.org/resources/java-champions.yml"
-
-		resp, err := shared.SendGet(url, "")
-		if err != nil {
-			fmt.Println("Error fetching the URL: " + err.Error())
-			return false, fmt.Errorf("Error fetching the Java Champion list: " + err.Error())
-		}
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return false, fmt.Errorf("Error reading response body: " + err.Error())
-		}
-		defer resp.Body.Close()
-
-		var response JCResponse
-		err = yaml.Unmarshal(respBody, &response)
+	verificationFunc := func(verificationId string, bskyHandle string, bskyDid string) (bool, error) {
+		shared.LogInfo("Validating Java Champion", shared.Field("verificationId", verificationId))
+		members, err := getMembers()
 		if err != nil {
-			fmt.Println("Error decoding Java Champion YAML: " + err.Error())
-			return false, fmt.Errorf("Error decoding Java Champion YAML: " + err.Error())
+			return false, err
 		}
 
 		// check if bsky handle is in JC profile
-		found := false
-		for _, member := range response.Members {
+		for _, member := range members {
 			if member.Name == verificationId {
-				fmt.Print("Java Champion with name '" + verificationId + "' found\n")
-				if member.Social.Bluesky == "https://bsky.app/profile/"+bskyHandle {
-					fmt.Print("Java Champion with name '" + verificationId + "' and handle '" + bskyHandle + "' found\n")
-					found = true
+				shared.LogDebug("Java Champion found", shared.Field("verificationId", verificationId))
+				if shared.MatchesBlueskyProfile(member.Social.Bluesky, bskyHandle, bskyDid) {
+					shared.LogInfo("Java Champion with handle found", shared.Field("verificationId", verificationId), shared.Field("handle", bskyHandle))
 					return true, nil
 				}
 			}
 		}
-		if !found {
-			fmt.Print("Java Champion with name '" + verificationId + "' and handle '" + bskyHandle + "' not found\n")
-			return false, fmt.Errorf("Link to social network with handle %s not found for Java Champion %s", bskyHandle, verificationId)
-		} else {
-			return true, nil
+		shared.LogInfo("Java Champion with handle not found", shared.Field("verificationId", verificationId), shared.Field("handle", bskyHandle))
+		return false, fmt.Errorf("Link to social network with handle %s not found for Java Champion %s", bskyHandle, verificationId)
+	}
+
+	existenceFunc := func(verificationId string) (bool, error) {
+		shared.LogInfo("Checking if Java Champion exists", shared.Field("verificationId", verificationId))
+		members, err := getMembers()
+		if err != nil {
+			return false, err
 		}
+		for _, member := range members {
+			if member.Name == verificationId {
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("Java Champion %s not found", verificationId)
 	}
-	moduleSpecifics.NamingFunc = func(m shared.ModuleSpecifics, _ string) (shared.Naming, error) {
-		return shared.SetupNamingStructure(m)
+
+	shared.ServeModuleWithExistence("javachamps", verificationFunc, nil, existenceFunc)
+}
+
+func getMembers() ([]Member, error) {
+	url := "https://javachampions.org/resources/java-champions.yml"
+
+	resp, err := shared.SendGet(url, "")
+	if err != nil {
+		shared.LogError("Error fetching the URL", shared.Field("url", url), shared.Field("error", err))
+		return nil, fmt.Errorf("Error fetching the Java Champion list: " + err.Error())
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading response body: " + err.Error())
 	}
 
-	spinhttp.Handle(moduleSpecifics.Handle)
+	var response JCResponse
+	err = yaml.Unmarshal(respBody, &response)
+	if err != nil {
+		shared.LogError("Error decoding Java Champion YAML", shared.Field("error", err))
+		return nil, fmt.Errorf("Error decoding Java Champion YAML: " + err.Error())
+	}
+	return response.Members, nil
 }
 
 func main() {}
@@ -6,8 +6,6 @@ import (
 	"net/url"
 
 	"github.com/shared"
-
-	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
 )
 
 type UserProfile struct {
@@ -30,26 +28,24 @@ type Response struct {
 }
 
 func init() {
-	moduleSpecifics, _ := shared.GetModuleSpecifics("mvp")
-
-	moduleSpecifics.VerificationFunc = func(verificationId string, bskyHandle string) (bool, error) {
+	verificationFunc := func(verificationId string, bskyHandle string, bskyDid string) (bool, error) {
 		// get MVP profile
-		fmt.Println("Validating MVP with ID: " + verificationId)
+		shared.LogInfo("Validating MVP", shared.Field("verificationId", verificationId))
 		profile, err := getMvpProfile(verificationId)
 		if err != nil {
 			return false, err
 		}
 
 		// check if bsky handle is in MVP profile
-		if containsSocialNetworkWithHandle(profile.UserProfile.UserProfileSocialNetwork, bskyHandle) {
-			fmt.Print("Social network with handle '" + bskyHandle + "' found\n")
+		if containsSocialNetworkWithHandle(profile.UserProfile.UserProfileSocialNetwork, bskyHandle, bskyDid) {
+			shared.LogInfo("Social network with handle found", shared.Field("verificationId", verificationId), shared.Field("handle", bskyHandle))
 			return true, nil
 		} else {
-			fmt.Print("Social network with handle '" + bskyHandle + "' not found\n")
+			shared.LogInfo("Social network with handle not found", shared.Field("verificationId", verificationId), shared.Field("handle", bskyHandle))
 			return false, fmt.Errorf(fmt.Sprintf("Link to social network with handle %s not found for MVP %s", bskyHandle, verificationId))
 		}
 	}
-	moduleSpecifics.NamingFunc = func(m shared.ModuleSpecifics, verificationId string) (shared.Naming, error) {
+	namingFunc := func(m shared.ModuleSpecifics, verificationId string) (shared.Naming, error) {
 		profile, err := getMvpProfile(verificationId)
 		if err != nil {
 			return shared.Naming{}, err
@@ -72,7 +68,7 @@ func init() {
 		})
 	}
 
-	spinhttp.Handle(moduleSpecifics.Handle)
+	shared.ServeModule("mvp", verificationFunc, namingFunc)
 }
 
 func getMvpProfile(verificationId string) (Response, error) {
@@ -80,7 +76,7 @@ func getMvpProfile(verificationId string) (Response, error) {
 
 	resp, err := shared.SendGet(url, "")
 	if err != nil {
-		fmt.Println("Error fetching the URL: " + err.Error())
+		shared.LogError("Error fetching the URL", shared.Field("url", url), shared.Field("error", err))
 		return Response{}, fmt.Errorf("Error fetching the MVP profile, probably caused by an invalid MVP ID: " + err.Error())
 	}
 	defer resp.Body.Close()
@@ -88,16 +84,16 @@ func getMvpProfile(verificationId string) (Response, error) {
 	var response Response
 	err = json.NewDecoder(resp.Body).Decode(&response)
 	if err != nil {
-		fmt.Println("Error decoding MVP JSON: " + err.Error())
+		shared.LogError("Error decoding MVP JSON", shared.Field("error", err))
 		return Response{}, fmt.Errorf("Error decoding MVP JSON, probably caused by an invalid MVP ID: " + err.Error())
 	}
 
 	return response, nil
 }
 
-func containsSocialNetworkWithHandle(socialNetworks []SocialNetwork, handle string) bool {
+func containsSocialNetworkWithHandle(socialNetworks []SocialNetwork, handle string, did string) bool {
 	for _, sn := range socialNetworks {
-		if sn.Handle == handle || sn.Handle == "bsky.app/profile/"+handle {
+		if shared.MatchesBlueskyProfile(sn.Handle, handle, did) {
 			return true
 		}
 	}
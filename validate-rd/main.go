@@ -7,8 +7,6 @@ import (
 	"net/url"
 
 	"github.com/shared"
-
-	spinhttp "github.com/fermyon/spin/sdk/go/v2/http"
 )
 
 type UserProfile struct {
@@ -32,15 +30,14 @@ type Response struct {
 
 func init() {
 
-	moduleSpecifics, _ := shared.GetModuleSpecifics("rd")
-	moduleSpecifics.VerificationFunc = func(verificationId string, bskyHandle string) (bool, error) {
+	verificationFunc := func(verificationId string, bskyHandle string, bskyDid string) (bool, error) {
 		// get RD profile
-		fmt.Println("Validating RD with ID: " + verificationId)
+		shared.LogInfo("Validating RD", shared.Field("verificationId", verificationId))
 		url := fmt.Sprintf("https://mavenapi-prod.azurewebsites.net/api/rd/UserProfiles/public/%s", url.QueryEscape(verificationId))
 
 		resp, err := shared.SendGet(url, "")
 		if err != nil {
-			fmt.Println("Error fetching the URL: " + err.Error())
+			shared.LogError("Error fetching the URL", shared.Field("url", url), shared.Field("error", err))
 			return false, fmt.Errorf("Error fetching the RD profile, probably caused by an invalid RD ID: "+err.Error(), http.StatusInternalServerError)
 		}
 		defer resp.Body.Close()
@@ -48,29 +45,26 @@ func init() {
 		var response Response
 		err = json.NewDecoder(resp.Body).Decode(&response)
 		if err != nil {
-			fmt.Println("Error decoding RD JSON: " + err.Error())
+			shared.LogError("Error decoding RD JSON", shared.Field("error", err))
 			return false, fmt.Errorf("Error decoding RD JSON, probably caused by an invalid RD ID: "+err.Error(), http.StatusInternalServerError)
 		}
 
 		// check if bsky handle is in RD profile
-		if containsSocialNetworkWithHandle(response.UserProfile.UserProfileSocialNetwork, bskyHandle) {
-			fmt.Print("Social network with handle '" + bskyHandle + "' found\n")
+		if containsSocialNetworkWithHandle(response.UserProfile.UserProfileSocialNetwork, bskyHandle, bskyDid) {
+			shared.LogInfo("Social network with handle found", shared.Field("verificationId", verificationId), shared.Field("handle", bskyHandle))
 			return true, nil
 		} else {
-			fmt.Print("Social network with handle '" + bskyHandle + "' not found\n")
+			shared.LogInfo("Social network with handle not found", shared.Field("verificationId", verificationId), shared.Field("handle", bskyHandle))
 			return false, fmt.Errorf("Link to social network with handle %s not found for RD %s", bskyHandle, verificationId)
 		}
 	}
-	moduleSpecifics.NamingFunc = func(m shared.ModuleSpecifics, _ string) (shared.Naming, error) {
-		return shared.SetupNamingStructure(m)
-	}
 
-	spinhttp.Handle(moduleSpecifics.Handle)
+	shared.ServeModule("rd", verificationFunc, nil)
 }
 
-func containsSocialNetworkWithHandle(socialNetworks []SocialNetwork, handle string) bool {
+func containsSocialNetworkWithHandle(socialNetworks []SocialNetwork, handle string, did string) bool {
 	for _, sn := range socialNetworks {
-		if sn.Handle == handle || sn.Handle == "bsky.app/profile/"+handle {
+		if shared.MatchesBlueskyProfile(sn.Handle, handle, did) {
 			return true
 		}
 	}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/shared"
+)
+
+// verifiedEvents are the events where the speaker links to the Bluesky profile, set by the verification of this
+// request, as Spin handles every request in a new instance. It is nil in the challenge and existence modes and on
+// delete, where every event that lists the speaker counts
+var verifiedEvents []string
+
+func init() {
+	verificationFunc := func(verificationId string, bskyHandle string, bskyDid string) (bool, error) {
+		shared.LogInfo("Validating conference speaker", shared.Field("verificationId", verificationId))
+
+		events, failures := getEventsOfSpeaker(verificationId, bskyHandle, bskyDid)
+		if len(events) > 0 {
+			verifiedEvents = events
+			return true, nil
+		}
+		return false, &shared.SessionizeSpeakerError{Message: "Speaker " + verificationId + " could not be verified at any event", Events: failures}
+	}
+
+	namingFunc := func(m shared.ModuleSpecifics, verificationId string) (shared.Naming, error) {
+		// only the events with the Bluesky link get the speaker on their lists and starter packs
+		events := verifiedEvents
+		if events == nil {
+			events, _ = getEventsOfSpeaker(verificationId, "", "")
+		}
+		firstAndSecondLevel := map[string][]string{}
+		for _, eventName := range events {
+			firstAndSecondLevel[eventName] = []string{}
+		}
+		m.FirstAndSecondLevel = firstAndSecondLevel
+		return shared.SetupNamingStructure(m)
+	}
+
+	existenceFunc := func(verificationId string) (bool, error) {
+		shared.LogInfo("Checking if conference speaker exists", shared.Field("verificationId", verificationId))
+		events, failures := getEventsOfSpeaker(verificationId, "", "")
+		if len(events) > 0 {
+			return true, nil
+		}
+		return false, &shared.SessionizeSpeakerError{Message: "Speaker " + verificationId + " is not listed at any event", Events: failures}
+	}
+
+	shared.ServeModuleWithExistence("sessionize", verificationFunc, namingFunc, existenceFunc)
+}
+
+// getEventsOfSpeaker returns the names of all configured events where the speaker links to the Bluesky profile
+// and the reasons why it failed for the others. If bskyHandle is empty, all events where the speaker is listed
+// are returned
+func getEventsOfSpeaker(verificationId string, bskyHandle string, bskyDid string) ([]string, []*shared.SessionizeVerificationError) {
+	sessionizeIds := []string{}
+	for sessionizeId := range shared.SessionizeEvents {
+		sessionizeIds = append(sessionizeIds, sessionizeId)
+	}
+	sort.Strings(sessionizeIds)
+
+	events := []string{}
+	failures := []*shared.SessionizeVerificationError{}
+	for _, sessionizeId := range sessionizeIds {
+		eventName := shared.SessionizeEvents[sessionizeId]
+		var err error
+		if bskyHandle == "" {
+			_, err = shared.GetSessionizeSpeakerProfileAtEvent(verificationId, sessionizeId)
+		} else {
+			_, err = shared.SessionizeVerification(verificationId, bskyHandle, bskyDid, sessionizeId)
+		}
+		if err != nil {
+			shared.LogInfo("Speaker not verified at event", shared.Field("verificationId", verificationId), shared.Field("event", eventName), shared.Field("error", err))
+			var failure *shared.SessionizeVerificationError
+			if !errors.As(err, &failure) {
+				failure = &shared.SessionizeVerificationError{Reason: shared.SessionizeEventUnavailable, SessionizeId: sessionizeId, VerificationId: verificationId, Details: err.Error()}
+			}
+			failure.Event = eventName
+			failures = append(failures, failure)
+			continue
+		}
+		events = append(events, eventName)
+	}
+	return events, failures
+}
+
+func main() {}
@@ -1,4 +1,4 @@
-module github.com/validate_awshero
+module github.com/validate_source
 
 go 1.20
 
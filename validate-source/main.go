@@ -0,0 +1,12 @@
+package main
+
+import (
+	"github.com/shared"
+)
+
+func init() {
+	// serves every verification source defined in shared/sources
+	shared.ServeSources()
+}
+
+func main() {}
@@ -27,12 +27,14 @@ const (
 
 type FailureCountRequest struct {
 	BskyHandle   string `json:"bskyHandle"`
+	Did          string `json:"did"`
 	ModuleKey    string `json:"moduleKey"`
 	FailureCount int    `json:"failureCount"`
 }
 
 type ValidationResult struct {
 	BskyHandle    string                  `json:"bskyHandle"`
+	Did           string                  `json:"did"`
 	ModuleResults map[string]ModuleResult `json:"moduleResults"`
 	Action        string                  `json:"action"` // "none", "partial_removal", "full_removal"
 }
@@ -44,6 +46,7 @@ type ModuleResult struct {
 	Removed        bool   `json:"removed"`
 	MessageSent    bool   `json:"messageSent"`
 	MessageSuccess bool   `json:"messageSuccess"`
+	HandleUpdated  bool   `json:"handleUpdated"`
 }
 
 func init() {
@@ -100,7 +103,29 @@ func handleFailureCountUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 	defer defaultStore.Close()
 
-	failureKey := fmt.Sprintf("failure-%s-%s", request.ModuleKey, request.BskyHandle)
+	// Get access to Bluesky API for notifications
+	accessJwt, endpoint, err := shared.LoginToBsky()
+	if err != nil {
+		shared.LogWarn("Could not login to Bluesky for notifications", shared.Field("error", err))
+	}
+
+	// Older callers only send the handle, so resolve the DID if it is missing
+	if request.Did == "" {
+		if accessJwt == "" {
+			http.Error(w, "DID required", http.StatusBadRequest)
+			return
+		}
+		request.Did, err = shared.ResolveDid(request.BskyHandle, accessJwt, endpoint)
+		if err != nil {
+			http.Error(w, "Error resolving DID for "+request.BskyHandle+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if request.BskyHandle == "" {
+		request.BskyHandle = request.Did
+	}
+
+	failureKey := fmt.Sprintf("failure-%s-%s", request.ModuleKey, request.Did)
 
 	// Update failure count for this specific module
 	err = failureStore.Set(failureKey, []byte(strconv.Itoa(request.FailureCount)))
@@ -108,19 +133,21 @@ func handleFailureCountUpdate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Error setting failure count: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// Failure counts used to be stored by handle, clean up the old key if there is one
+	legacyFailureKey := fmt.Sprintf("failure-%s-%s", request.ModuleKey, request.BskyHandle)
+	if legacyFailureKey != failureKey {
+		if exists, _ := failureStore.Exists(legacyFailureKey); exists {
+			failureStore.Delete(legacyFailureKey)
+		}
+	}
 
 	result := ValidationResult{
 		BskyHandle:    request.BskyHandle,
+		Did:           request.Did,
 		ModuleResults: make(map[string]ModuleResult),
 		Action:        "none",
 	}
 
-	// Get access to Bluesky API for notifications
-	accessJwt, endpoint, err := shared.LoginToBsky()
-	if err != nil {
-		fmt.Printf("Warning: Could not login to Bluesky for notifications: %v\n", err)
-	}
-
 	// Add the updated module result with notification tracking
 	moduleResult := ModuleResult{
 		ModuleKey:      request.ModuleKey,
@@ -134,25 +161,25 @@ func handleFailureCountUpdate(w http.ResponseWriter, r *http.Request) {
 	// Handle notifications for warning and max failure counts
 	if accessJwt != "" {
 		if request.FailureCount == WarningFailureCount {
-			message := fmt.Sprintf("⚠️ Hi! Your verification for the %s module has failed %d times for the account @%s in our weekly validation. If failures continue %d times more, you will be removed from the verified lists and lose the label. Please check your profile/verification source to ensure it still meets the requirements. If you renamed your account since getting verified, please try again with the new account name on https://verifiedbsky.net.", request.ModuleKey, WarningFailureCount, request.BskyHandle, MaxFailureCount-WarningFailureCount)
+			message := fmt.Sprintf("⚠️ Hi! Your verification for the %s module has failed %d times for the account @%s in our weekly validation. If failures continue %d times more, you will be removed from the verified lists and lose the label. Please check your profile/verification source to ensure it still meets the requirements. If you renamed your account since getting verified, please make sure your profile at the verification source links to your new account name.", request.ModuleKey, WarningFailureCount, request.BskyHandle, MaxFailureCount-WarningFailureCount)
 			moduleResult.MessageSent = true
-			err = shared.SendDirectMessage(request.BskyHandle, message, accessJwt, endpoint)
+			err = shared.SendDirectMessage(request.Did, message, accessJwt, endpoint)
 			if err != nil {
-				fmt.Printf("Failed to send warning direct message to %s: %v\n", request.BskyHandle, err)
+				shared.LogError("Failed to send warning direct message", shared.Field("handle", request.BskyHandle), shared.Field("error", err))
 				moduleResult.MessageSuccess = false
 			} else {
-				fmt.Printf("Warning direct message sent successfully to %s\n", request.BskyHandle)
+				shared.LogInfo("Warning direct message sent successfully", shared.Field("handle", request.BskyHandle))
 				moduleResult.MessageSuccess = true
 			}
 		} else if request.FailureCount >= MaxFailureCount {
-			message := fmt.Sprintf("❌ Hi! Your verification for the %s module has failed %d times for the account @%s and you have been removed from the verified lists and lost the label. You can re-apply for verification at any time if you meet the requirements again. If you renamed your account since getting verified, please try again with the new account name on https://verifiedbsky.net.", request.ModuleKey, MaxFailureCount, request.BskyHandle)
+			message := fmt.Sprintf("❌ Hi! Your verification for the %s module has failed %d times for the account @%s and you have been removed from the verified lists and lost the label. You can re-apply for verification at any time if you meet the requirements again on https://verifiedbsky.net.", request.ModuleKey, MaxFailureCount, request.BskyHandle)
 			moduleResult.MessageSent = true
-			err = shared.SendDirectMessage(request.BskyHandle, message, accessJwt, endpoint)
+			err = shared.SendDirectMessage(request.Did, message, accessJwt, endpoint)
 			if err != nil {
-				fmt.Printf("Failed to send removal direct message to %s: %v\n", request.BskyHandle, err)
+				shared.LogError("Failed to send removal direct message", shared.Field("handle", request.BskyHandle), shared.Field("error", err))
 				moduleResult.MessageSuccess = false
 			} else {
-				fmt.Printf("Removal direct message sent successfully to %s\n", request.BskyHandle)
+				shared.LogInfo("Removal direct message sent successfully", shared.Field("handle", request.BskyHandle))
 				moduleResult.MessageSuccess = true
 			}
 		}
@@ -160,42 +187,53 @@ func handleFailureCountUpdate(w http.ResponseWriter, r *http.Request) {
 
 	result.ModuleResults[request.ModuleKey] = moduleResult
 
+	// Remember when the verification was last confirmed
+	if request.FailureCount == 0 {
+		validatedKey, err := findStoreKey(defaultStore, request.ModuleKey, request.Did, request.BskyHandle)
+		if err != nil {
+			shared.LogError("Error getting keys", shared.Field("error", err))
+		} else if validatedKey != "" {
+			err = shared.MarkValidated(validatedKey)
+			if err != nil {
+				shared.LogError("Error updating last validation", shared.Field("key", validatedKey), shared.Field("error", err))
+			}
+			// labels of time-bound programs expire unless they are renewed
+			if accessJwt != "" {
+				err = shared.RenewLabels(validatedKey, accessJwt, endpoint)
+				if err != nil {
+					shared.LogError("Error renewing labels", shared.Field("key", validatedKey), shared.Field("error", err))
+				}
+			}
+		}
+	}
+
 	// If failure count reaches the maximum threshold, remove the user from this specific module
 	if request.FailureCount >= MaxFailureCount {
-		fmt.Printf("Removing user %s from module %s due to %d consecutive failures\n", request.BskyHandle, request.ModuleKey, MaxFailureCount)
+		shared.LogInfo("Removing user from module due to consecutive failures", shared.Field("handle", request.BskyHandle), shared.Field("module", request.ModuleKey), shared.Field("failures", MaxFailureCount))
 
 		// Find and remove the specific key for this module and user
-		keys, err := defaultStore.GetKeys()
+		keyToRemove, err := findStoreKey(defaultStore, request.ModuleKey, request.Did, request.BskyHandle)
 		if err != nil {
 			http.Error(w, "Error getting keys: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		var keyToRemove string
-		for _, key := range keys {
-			if strings.HasPrefix(key, request.ModuleKey+"-") && !strings.HasPrefix(key, "failure-") &&
-				key != "endpoint" && key != "accessJwt" && key != "" {
-				value, err := defaultStore.Get(key)
-				if err != nil {
-					continue
-				}
-				if string(value) == request.BskyHandle {
-					keyToRemove = key
-					break
-				}
-			}
-		}
-
 		if keyToRemove != "" {
-			fmt.Printf("Removing key %s for user %s from module %s\n", keyToRemove, request.BskyHandle, request.ModuleKey)
+			shared.LogInfo("Removing key", shared.Field("key", keyToRemove), shared.Field("handle", request.BskyHandle), shared.Field("module", request.ModuleKey))
+			// the entry knows the level labels that were applied
+			entry := shared.StoreEntry{}
+			value, err := defaultStore.Get(keyToRemove)
+			if err == nil {
+				entry = shared.ParseStoreEntryWithKey(keyToRemove, value)
+			}
 			err = defaultStore.Delete(keyToRemove)
 			if err != nil {
-				fmt.Printf("Error deleting key %s: %v\n", keyToRemove, err)
+				shared.LogError("Error deleting key", shared.Field("key", keyToRemove), shared.Field("error", err))
 			} else {
 				// Remove from Bluesky lists and starter packs, and remove label for this module
-				err = removeFromBlueskyAndLabel(keyToRemove, request.BskyHandle)
+				err = removeFromBlueskyAndLabel(keyToRemove, request.Did, entry)
 				if err != nil {
-					fmt.Printf("Error removing from Bluesky for key %s: %v\n", keyToRemove, err)
+					shared.LogError("Error removing from Bluesky", shared.Field("key", keyToRemove), shared.Field("error", err))
 				}
 
 				moduleResult.Removed = true
@@ -207,7 +245,7 @@ func handleFailureCountUpdate(w http.ResponseWriter, r *http.Request) {
 		// Remove failure count key for this module
 		err = failureStore.Delete(failureKey)
 		if err != nil {
-			fmt.Printf("Error deleting failure key: %v\n", err)
+			shared.LogError("Error deleting failure key", shared.Field("error", err))
 		}
 	}
 
@@ -225,21 +263,35 @@ func handleFailureCountUpdate(w http.ResponseWriter, r *http.Request) {
 
 func handleValidationCheck(w http.ResponseWriter, r *http.Request) {
 	// Authenticate request
-	_, _, err := shared.LoginToBskyWithReq(r)
+	accessJwt, endpoint, err := shared.LoginToBskyWithReq(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	// Extract bsky handle from URL path
+	// Extract bsky DID or handle from URL path
 	path := strings.TrimPrefix(r.URL.Path, "/weekly-validation/")
 	if path == "" {
-		http.Error(w, "Bluesky handle required", http.StatusBadRequest)
+		http.Error(w, "Bluesky DID or handle required", http.StatusBadRequest)
 		return
 	}
 
 	segments := strings.SplitN(path, "/", 2)
-	bskyHandle := strings.ToLower(segments[0])
+	bskyHandle := ""
+	bskyDid := ""
+	if strings.HasPrefix(segments[0], "did:") {
+		bskyDid = segments[0]
+	} else {
+		bskyHandle = strings.ToLower(segments[0])
+	}
+
+	profile, err := shared.GetProfile(segments[0], accessJwt, endpoint)
+	if err != nil {
+		shared.LogWarn("Could not get profile, continuing with stored data", shared.Field("actor", segments[0]), shared.Field("error", err))
+	} else {
+		bskyDid = profile.DID
+		bskyHandle = profile.Handle
+	}
 
 	store, err := kv.OpenStore("default")
 	if err != nil {
@@ -257,6 +309,7 @@ func handleValidationCheck(w http.ResponseWriter, r *http.Request) {
 
 	result := ValidationResult{
 		BskyHandle:    bskyHandle,
+		Did:           bskyDid,
 		ModuleResults: make(map[string]ModuleResult),
 		Action:        "none",
 	}
@@ -269,41 +322,66 @@ func handleValidationCheck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Track which modules this user has verification entries for
-	userModules := make(map[string]string) // moduleKey -> verificationId
+	userModules := make(map[string]string)            // moduleKey -> verificationId
+	userEntries := make(map[string]shared.StoreEntry) // moduleKey -> stored entry
+	userKeys := make(map[string]string)               // moduleKey -> store key
 
 	for _, key := range keys {
-		if strings.Contains(key, "-") && !strings.HasPrefix(key, "failure-") &&
-			key != "endpoint" && key != "accessJwt" && key != "" {
+		if shared.IsVerificationKey(key) && !strings.HasPrefix(key, "failure-") {
 			value, err := store.Get(key)
 			if err != nil {
 				continue
 			}
-			if string(value) == bskyHandle {
-				parts := strings.Split(key, "-")
-				if len(parts) >= 2 {
-					moduleKey := parts[0]
-					verificationId := strings.Join(parts[1:], "-")
-					userModules[moduleKey] = verificationId
-				}
+			entry := shared.ParseStoreEntryWithKey(key, value)
+			if entry.BelongsTo(bskyDid, bskyHandle) {
+				userModules[entry.ModuleKey] = entry.VerificationId
+				userEntries[entry.ModuleKey] = entry
+				userKeys[entry.ModuleKey] = key
 			}
 		}
 	}
 
 	// Check validation status and failure counts for each module
 	for moduleKey, verificationId := range userModules {
-		// Get current failure count for this module
-		failureKey := fmt.Sprintf("failure-%s-%s", moduleKey, bskyHandle)
+		// Get current failure count for this module, falling back to the old handle based key
 		failureCount := 0
-		if exists, _ := failureStore.Exists(failureKey); exists {
-			if failureData, err := failureStore.Get(failureKey); err == nil {
-				if count, err := strconv.Atoi(string(failureData)); err == nil {
-					failureCount = count
+		for _, failureKey := range []string{fmt.Sprintf("failure-%s-%s", moduleKey, bskyDid), fmt.Sprintf("failure-%s-%s", moduleKey, bskyHandle)} {
+			if exists, _ := failureStore.Exists(failureKey); exists {
+				if failureData, err := failureStore.Get(failureKey); err == nil {
+					if count, err := strconv.Atoi(string(failureData)); err == nil {
+						failureCount = count
+					}
 				}
+				break
 			}
 		}
 
-		// Check if validation is still valid
-		isValid := checkValidation(moduleKey, verificationId, bskyHandle)
+		// Check if validation is still valid, using the handle the user was verified with
+		verifiedHandle := userEntries[moduleKey].Handle
+		if verifiedHandle == "" {
+			verifiedHandle = bskyHandle
+		}
+		// accounts verified with a challenge token don't have a link at the source, so only the profile is checked
+		proofMode := userEntries[moduleKey].ProofMode
+		if proofMode == shared.ProofModeChallenge {
+			proofMode = shared.ProofModeExistence
+		}
+		isValid := checkValidation(moduleKey, verificationId, verifiedHandle, proofMode)
+
+		// If the account was renamed, the verification source may already link to the new handle
+		handleUpdated := false
+		if !isValid && bskyHandle != "" && bskyHandle != verifiedHandle {
+			shared.LogInfo("Handle changed, retrying validation with the current handle", shared.Field("did", bskyDid), shared.Field("from", verifiedHandle), shared.Field("to", bskyHandle), shared.Field("module", moduleKey))
+			isValid = checkValidation(moduleKey, verificationId, bskyHandle, proofMode)
+			if isValid {
+				err = shared.UpdateStoredHandle(userKeys[moduleKey], bskyHandle)
+				if err != nil {
+					shared.LogError("Error updating handle", shared.Field("key", userKeys[moduleKey]), shared.Field("error", err))
+				} else {
+					handleUpdated = true
+				}
+			}
+		}
 
 		result.ModuleResults[moduleKey] = ModuleResult{
 			ModuleKey:      moduleKey,
@@ -312,6 +390,7 @@ func handleValidationCheck(w http.ResponseWriter, r *http.Request) {
 			Removed:        false,
 			MessageSent:    false,
 			MessageSuccess: false,
+			HandleUpdated:  handleUpdated,
 		}
 	}
 
@@ -326,7 +405,7 @@ func handleValidationCheck(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, string(jsonResult))
 }
 
-func checkValidation(moduleKey, verificationId, bskyHandle string) bool {
+func checkValidation(moduleKey, verificationId, bskyHandle, proofMode string) bool {
 	// This would call the appropriate validation endpoint
 	// For now, we'll use a simple HTTP client to call the validation endpoint
 	// Base URL can be configured via Spin variable to allow localhost testing
@@ -335,22 +414,31 @@ func checkValidation(moduleKey, verificationId, bskyHandle string) bool {
 		baseURL = "https://verifiedbsky.net"
 	}
 	baseURL = strings.TrimRight(baseURL, "/")
-	url := fmt.Sprintf("%s/validate-%s/?verify_only=true", baseURL, moduleKey)
+
+	moduleSpecifics, err := shared.GetModuleSpecifics(moduleKey)
+	if err != nil {
+		shared.LogWarn("Module is not registered", shared.Field("module", moduleKey), shared.Field("error", err))
+		return false
+	}
+	url := fmt.Sprintf("%s%s/?verify_only=true", baseURL, moduleSpecifics.ValidationPath())
 
 	requestBody := map[string]string{
 		"verificationId": verificationId,
 		"bskyHandle":     bskyHandle,
 	}
+	if proofMode != "" {
+		requestBody["proofMode"] = proofMode
+	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		fmt.Printf("Error marshaling request for %s: %v\n", moduleKey, err)
+		shared.LogError("Error marshaling request", shared.Field("module", moduleKey), shared.Field("error", err))
 		return false
 	}
 
 	resp, err := shared.SendPost(url, string(jsonData), "application/json")
 	if err != nil {
-		fmt.Printf("Error validating %s with %s: %v\n", bskyHandle, moduleKey, err)
+		shared.LogError("Error validating", shared.Field("handle", bskyHandle), shared.Field("module", moduleKey), shared.Field("error", err))
 		return false
 	}
 	defer resp.Body.Close()
@@ -358,7 +446,28 @@ func checkValidation(moduleKey, verificationId, bskyHandle string) bool {
 	return resp.StatusCode == http.StatusOK
 }
 
-func removeFromBlueskyAndLabel(key, bskyHandle string) error {
+// findStoreKey returns the key of the verification of the given account in a module or an empty string if there is none
+func findStoreKey(store *kv.Store, moduleKey string, bskyDid string, bskyHandle string) (string, error) {
+	keys, err := store.GetKeys()
+	if err != nil {
+		return "", err
+	}
+
+	for _, key := range keys {
+		if strings.HasPrefix(key, moduleKey+"-") && shared.IsVerificationKey(key) {
+			value, err := store.Get(key)
+			if err != nil {
+				continue
+			}
+			if shared.ParseStoreEntry(value).BelongsTo(bskyDid, bskyHandle) {
+				return key, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func removeFromBlueskyAndLabel(key, bskyDid string, entry shared.StoreEntry) error {
 	accessJwt, endpoint, err := shared.LoginToBsky()
 	if err != nil {
 		return fmt.Errorf("error logging in to Bluesky: %v", err)
@@ -385,7 +494,7 @@ func removeFromBlueskyAndLabel(key, bskyHandle string) error {
 	// Get module specifics to determine which lists/starter packs belong to this module
 	moduleSpecifics, err := shared.GetModuleSpecifics(moduleKey)
 	if err != nil {
-		fmt.Printf("Error getting module specifics for %s: %v\n", moduleKey, err)
+		shared.LogError("Error getting module specifics", shared.Field("module", moduleKey), shared.Field("error", err))
 		return fmt.Errorf("error getting module specifics for %s: %v", moduleKey, err)
 	}
 
@@ -408,10 +517,10 @@ func removeFromBlueskyAndLabel(key, bskyHandle string) error {
 	// Remove from lists that belong to this module only
 	for _, list := range allLists {
 		if moduleNames[list.Name] {
-			fmt.Printf("Removing user from module-specific list: %s\n", list.Name)
-			_, err = shared.CheckOrDeleteUserOnList(list.URI, bskyHandle, true, accessJwt, endpoint)
+			shared.LogInfo("Removing user from module-specific list", shared.Field("list", list.Name))
+			_, err = shared.CheckOrDeleteUserOnList(list.URI, bskyDid, true, accessJwt, endpoint)
 			if err != nil {
-				fmt.Printf("Error removing user from list %s: %v\n", list.Name, err)
+				shared.LogError("Error removing user from list", shared.Field("list", list.Name), shared.Field("error", err))
 			}
 		}
 	}
@@ -419,18 +528,20 @@ func removeFromBlueskyAndLabel(key, bskyHandle string) error {
 	// Remove from starter packs that belong to this module only
 	for _, starterPack := range allStarterPacks {
 		if moduleNames[starterPack.Record.Name] {
-			fmt.Printf("Removing user from module-specific starter pack: %s\n", starterPack.Record.Name)
-			_, err = shared.CheckOrDeleteUserOnList(starterPack.Record.List, bskyHandle, true, accessJwt, endpoint)
+			shared.LogInfo("Removing user from module-specific starter pack", shared.Field("starterPack", starterPack.Record.Name))
+			_, err = shared.CheckOrDeleteUserOnList(starterPack.Record.List, bskyDid, true, accessJwt, endpoint)
 			if err != nil {
-				fmt.Printf("Error removing user from starter pack %s: %v\n", starterPack.Record.Name, err)
+				shared.LogError("Error removing user from starter pack", shared.Field("starterPack", starterPack.Record.Name), shared.Field("error", err))
 			}
 		}
 	}
 
-	// Remove the label
-	err = shared.RemoveLabel(moduleSpecifics.ModuleLabel, bskyHandle, accessJwt, endpoint)
-	if err != nil {
-		fmt.Printf("Error removing label %s from %s: %v\n", moduleSpecifics.ModuleLabel, bskyHandle, err)
+	// Remove the module label and the labels of its levels
+	for _, label := range append([]string{moduleSpecifics.ModuleLabel}, moduleSpecifics.StoredLevelLabels(entry)...) {
+		err = shared.RemoveLabel(label, bskyDid, accessJwt, endpoint)
+		if err != nil {
+			shared.LogError("Error removing label", shared.Field("label", label), shared.Field("did", bskyDid), shared.Field("error", err))
+		}
 	}
 
 	return nil